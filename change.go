@@ -0,0 +1,98 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ggp
+
+// DefaultSubscribeBufferSize is the default number of changes buffered for one subscriber.
+const DefaultSubscribeBufferSize = 100
+
+// ChangeType is the type of a Change.
+type ChangeType string
+
+const (
+	// Added is the change of an object created, or entering the selector of the subscription.
+	Added ChangeType = "Added"
+	// Modified is the change of an object updated and still matching the selector of the subscription.
+	Modified ChangeType = "Modified"
+	// Deleted is the change of an object deleted, or leaving the selector of the subscription.
+	Deleted ChangeType = "Deleted"
+)
+
+// Change is a change of one object of a subscribed kind.
+// The objects are typed like the queries return them and shared with the caches, they must not be modified.
+type Change struct {
+	// Type is Added, Modified or Deleted.
+	Type ChangeType
+	// Kind is the subscribed kind, such as Pod.
+	Kind string
+	// Old is the object before the change, nil for Added.
+	Old interface{}
+	// New is the object after the change, nil for Deleted.
+	New interface{}
+}
+
+// Object return the object after the change, the last known one for Deleted.
+func (c Change) Object() interface{} {
+	if c.New != nil {
+		return c.New
+	}
+	return c.Old
+}
+
+// BackpressurePolicy is what a subscription does with the changes once its buffer is full.
+type BackpressurePolicy int
+
+const (
+	// DropPolicy drop the changes the buffer can not hold, see Subscription.Dropped.
+	DropPolicy BackpressurePolicy = iota
+	// BlockPolicy wait until the subscriber receives the changes the buffer can not hold,
+	// the changes of the kind to the other subscriptions wait too.
+	BlockPolicy
+)
+
+// SubscribeOptions is the options of Subscribe.
+type SubscribeOptions struct {
+	// BufferSize is the capacity of the channel of the changes, default DefaultSubscribeBufferSize.
+	BufferSize int
+	// Policy is what the subscription does once the buffer is full, default DropPolicy.
+	Policy BackpressurePolicy
+}
+
+// SubscribeOption configures a subscription.
+type SubscribeOption func(*SubscribeOptions)
+
+// WithBuffer set the capacity of the channel of the changes and what to do once it is full.
+func WithBuffer(size int, policy BackpressurePolicy) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		if size > 0 {
+			o.BufferSize = size
+		}
+		o.Policy = policy
+	}
+}
+
+// Subscription is the changes of one kind under a namespace matching a selector, see ControllerService.Subscribe.
+type Subscription interface {
+	// Changes return the channel of the changes, closed by Unsubscribe or once the controller is stopped for good.
+	Changes() <-chan Change
+	// Dropped return the number of changes dropped as the buffer was full.
+	Dropped() uint64
+	// Unsubscribe stop the changes and close the channel, it can be called several times.
+	Unsubscribe()
+	// Close stop the changes and close the channel once the change being sent is buffered, unlike Unsubscribe
+	// dropping it. It can be called several times.
+	Close()
+}
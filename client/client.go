@@ -17,152 +17,333 @@ limitations under the License.
 package client
 
 import (
+	"fmt"
 	istio "istio.io/client-go/pkg/clientset/versioned"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 )
 
+// ConfigSource is where the k8s rest config of the clients is loaded from.
+type ConfigSource string
+
+const (
+	// ConfigSourceFlags is the Master and KubeConfig of KubeAPIConfig.
+	ConfigSourceFlags ConfigSource = "flags"
+	// ConfigSourceEnv is the kubeconfig files of the $KUBECONFIG environment.
+	ConfigSourceEnv ConfigSource = "env"
+	// ConfigSourceInCluster is the service account of the pod running ggp.
+	ConfigSourceInCluster ConfigSource = "in-cluster"
+	// ConfigSourceBytes is the kubeconfig content given to NewManagerConfigClient.
+	ConfigSourceBytes ConfigSource = "bytes"
+	// ConfigSourceInterfaces is the clients given to NewManagerClientFromInterfaces, such as fakes.
+	ConfigSourceInterfaces ConfigSource = "interfaces"
+)
+
+// serviceAccountNamespaceFile is the namespace of the pod running ggp, mounted by k8s.
+const serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
 type ManagerClient struct {
+	// mu guards the clients, discovery, namespace, source and files, they are replaced by Reload.
+	mu            sync.RWMutex
 	kubeClient    kubernetes.Interface
 	dynamicClient dynamic.Interface
 	istioClient   istio.Interface
+	// metadataClient lists and watches the metadata of any kind only, see PartialObjectMetadata.
+	metadataClient metadata.Interface
+	// discovery is the cached discovery of the k8s apiserver.
+	discovery *Discovery
+	// namespace is the default namespace of the kubeconfig context or of the pod running ggp.
+	namespace string
+	// source is where the rest config is loaded from.
+	source ConfigSource
+	// config is the config the clients are loaded from, nil for NewManagerConfigClient.
+	config *KubeAPIConfig
+	// opts is the options of NewManagerClient, applied again by Reload.
+	opts []Option
+	// files is the kubeconfig, token and certificate files the clients are loaded from, see Watch.
+	files []string
+	// reloads is the functions called after Reload, keyed by the id returned to OnReload.
+	reloads   map[int]func()
+	nextID    int
+	reloadsMu sync.Mutex
+	// reloadMu serializes Reload, so the clients and the OnReload calls of one reload are not interleaved with another.
+	reloadMu sync.Mutex
 }
 
-func NewManagerClient(config *Config) (*ManagerClient, error) {
-	kubeClient, err := NewKubeClient(config.KubeAPIConfig)
+// NewManagerClient return the clients built from the first resolved source,
+// the explicit Master or KubeConfig first, then $KUBECONFIG, then the in-cluster service account.
+// Reload or Watch rebuild them once the kubeconfig or the token changes.
+func NewManagerClient(config *Config, opts ...Option) (*ManagerClient, error) {
+	kubeAPIConfig := *config.KubeAPIConfig
+	c, err := loadManagerClient(&kubeAPIConfig, opts)
 	if err != nil {
 		return nil, err
 	}
-	istioClient, err := NewIstioClient(config.KubeAPIConfig)
+	c.config = &kubeAPIConfig
+	c.opts = opts
+	return c, nil
+}
+
+// loadManagerClient return the clients of the first resolved source of config.
+func loadManagerClient(config *KubeAPIConfig, opts []Option) (*ManagerClient, error) {
+	restConfig, namespace, source, err := loadRestConfig(config)
 	if err != nil {
 		return nil, err
 	}
-	dynamicClient, err := NewDynamicClient(config.KubeAPIConfig)
+	c, err := newManagerClient(buildRestConfig(restConfig, config, opts), namespace, source)
 	if err != nil {
 		return nil, err
 	}
-	return &ManagerClient{
-		kubeClient:    kubeClient,
-		istioClient:   istioClient,
-		dynamicClient: dynamicClient,
-	}, nil
+	c.files = watchedFiles(config, restConfig, source)
+	return c, nil
 }
 
-func NewManagerConfigClient(kubeConfig []byte) (*ManagerClient, error) {
-	kubeClient, err := NewKubeConfigClient(kubeConfig)
+// NewManagerConfigClient return the clients built from the kubeconfig content, such as of a registered cluster.
+// The QPS, burst and content type are the defaults of NewKubeAPIConfig unless opts change them.
+func NewManagerConfigClient(kubeConfig []byte, opts ...Option) (*ManagerClient, error) {
+	clientConfig, err := clientcmd.NewClientConfigFromBytes(kubeConfig)
 	if err != nil {
 		return nil, err
 	}
-	dynamicClient, err := NewDynamicConfigClient(kubeConfig)
+	restConfig, err := clientConfig.ClientConfig()
 	if err != nil {
 		return nil, err
 	}
-	istioClient, err := NewIstioConfigClient(kubeConfig)
+	namespace, _, err := clientConfig.Namespace()
 	if err != nil {
 		return nil, err
 	}
+	return newManagerClient(buildRestConfig(restConfig, NewKubeAPIConfig(), opts), namespace, ConfigSourceBytes)
+}
+
+// NewManagerClientFromInterfaces return the ManagerClient of the given clients, such as the fake clients of ggptest,
+// the discovery is the one of kubeClient. It is not reloadable, Reload returns ErrNotReloadable.
+func NewManagerClientFromInterfaces(kubeClient kubernetes.Interface, dynamicClient dynamic.Interface, istioClient istio.Interface,
+	metadataClient metadata.Interface, namespace string) *ManagerClient {
 	return &ManagerClient{
-		kubeClient:    kubeClient,
-		dynamicClient: dynamicClient,
-		istioClient:   istioClient,
-	}, nil
+		kubeClient:     kubeClient,
+		dynamicClient:  dynamicClient,
+		istioClient:    istioClient,
+		metadataClient: metadataClient,
+		discovery:      NewDiscovery(kubeClient.Discovery()),
+		namespace:      namespace,
+		source:         ConfigSourceInterfaces,
+	}
 }
 
-// TODO
-func NewRestClusterClient() (kubernetes.Interface, error) {
-	config, err := rest.InClusterConfig()
+// newManagerClient return the clients built from restConfig.
+func newManagerClient(restConfig *rest.Config, namespace string, source ConfigSource) (*ManagerClient, error) {
+	kubeClient, err := kubernetes.NewForConfig(forKubeClient(restConfig))
 	if err != nil {
 		return nil, err
 	}
-	clientSet, err := kubernetes.NewForConfig(config)
+	dynamicClient, err := dynamic.NewForConfig(forCRDClient(restConfig))
 	if err != nil {
 		return nil, err
 	}
-	return clientSet, nil
+	istioClient, err := istio.NewForConfig(forCRDClient(restConfig))
+	if err != nil {
+		return nil, err
+	}
+	// the metadata client negotiates its own content types.
+	metadataClient, err := metadata.NewForConfig(rest.CopyConfig(restConfig))
+	if err != nil {
+		return nil, err
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(forCRDClient(restConfig))
+	if err != nil {
+		return nil, err
+	}
+	return &ManagerClient{
+		kubeClient:     kubeClient,
+		dynamicClient:  dynamicClient,
+		istioClient:    istioClient,
+		metadataClient: metadataClient,
+		discovery:      NewDiscovery(discoveryClient),
+		namespace:      namespace,
+		source:         source,
+	}, nil
 }
 
-// NewKubeClient return use in pod container created by k8s.
-// TODO
-func NewKubeClient(config *KubeAPIConfig) (kubernetes.Interface, error) {
-	kubeConfig, err := clientcmd.BuildConfigFromFlags(config.Master, config.KubeConfig)
+// NewRestConfig return the rest config of the first resolved source and the source,
+// the explicit Master or KubeConfig first, then $KUBECONFIG, then the in-cluster service account.
+// The Context, Cluster and AuthInfo overrides apply to both kubeconfig sources.
+func NewRestConfig(config *KubeAPIConfig, opts ...Option) (*rest.Config, ConfigSource, error) {
+	restConfig, _, source, err := loadRestConfig(config)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	kubeConfig.QPS = float32(config.QPS)
-	kubeConfig.Burst = int(config.Burst)
-	kubeConfig.ContentType = config.ContentType
-	return kubernetes.NewForConfigOrDie(kubeConfig), nil
+	return buildRestConfig(restConfig, config, opts), source, nil
 }
 
-func NewDynamicClient(config *KubeAPIConfig) (dynamic.Interface, error) {
-	kubeConfig, err := clientcmd.BuildConfigFromFlags(config.Master, config.KubeConfig)
+// NewRestConfigFromBytes return the rest config of the kubeconfig content,
+// with the defaults of NewKubeAPIConfig unless opts change them.
+func NewRestConfigFromBytes(kubeConfig []byte, opts ...Option) (*rest.Config, error) {
+	restConfig, err := clientcmd.RESTConfigFromKubeConfig(kubeConfig)
 	if err != nil {
 		return nil, err
 	}
-	kubeConfig.QPS = float32(config.QPS)
-	kubeConfig.Burst = int(config.Burst)
-	kubeConfig.ContentType = config.ContentType
-	dynamicClient, err := dynamic.NewForConfig(kubeConfig)
+	return buildRestConfig(restConfig, NewKubeAPIConfig(), opts), nil
+}
+
+// loadRestConfig resolve the rest config and the default namespace, without the client settings.
+func loadRestConfig(config *KubeAPIConfig) (*rest.Config, string, ConfigSource, error) {
+	var clientConfig clientcmd.ClientConfig
+	var source ConfigSource
+	if config.Master != "" || config.KubeConfig != "" {
+		clientConfig, source = config.clientConfig(config.loadingRules()), ConfigSourceFlags
+	} else if env := os.Getenv(clientcmd.RecommendedConfigPathEnvVar); env != "" {
+		rules := &clientcmd.ClientConfigLoadingRules{Precedence: filepath.SplitList(env)}
+		clientConfig, source = config.clientConfig(rules), ConfigSourceEnv
+	}
+
+	if clientConfig == nil {
+		restConfig, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, "", "", fmt.Errorf("no kubeconfig given, $%s is empty and in-cluster config failed: %v", clientcmd.RecommendedConfigPathEnvVar, err)
+		}
+		return restConfig, inClusterNamespace(config), ConfigSourceInCluster, nil
+	}
+	restConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, "", "", err
+	}
+	namespace, _, err := clientConfig.Namespace()
+	if err != nil {
+		return nil, "", "", err
+	}
+	return restConfig, namespace, source, nil
+}
+
+// inClusterNamespace return the Namespace of config, or the namespace of the pod running ggp.
+func inClusterNamespace(config *KubeAPIConfig) string {
+	if config.Namespace != "" {
+		return config.Namespace
+	}
+	if data, err := os.ReadFile(serviceAccountNamespaceFile); err == nil {
+		if namespace := strings.TrimSpace(string(data)); namespace != "" {
+			return namespace
+		}
+	}
+	return metav1.NamespaceDefault
+}
+
+// NewRestClusterClient return use the service account of the pod created by k8s.
+// Deprecated: NewManagerClient falls back to the in-cluster config when no kubeconfig is given.
+func NewRestClusterClient(opts ...Option) (kubernetes.Interface, error) {
+	config, err := rest.InClusterConfig()
 	if err != nil {
 		return nil, err
 	}
-	return dynamicClient, nil
+	return kubernetes.NewForConfig(forKubeClient(buildRestConfig(config, NewKubeAPIConfig(), opts)))
 }
 
-func NewIstioClient(config *KubeAPIConfig) (istio.Interface, error) {
-	kubeConfig, err := clientcmd.BuildConfigFromFlags(config.Master, config.KubeConfig)
+// NewKubeClient return the k8s client of the first resolved source, see NewRestConfig.
+func NewKubeClient(config *KubeAPIConfig, opts ...Option) (kubernetes.Interface, error) {
+	restConfig, _, err := NewRestConfig(config, opts...)
 	if err != nil {
 		return nil, err
 	}
-	kubeConfig.QPS = float32(config.QPS)
-	kubeConfig.Burst = int(config.Burst)
-	kubeConfig.ContentType = config.ContentType
-	return istio.NewForConfigOrDie(kubeConfig), nil
+	return kubernetes.NewForConfig(forKubeClient(restConfig))
 }
 
-// NewKubeConfigClient return use ~/.kube/config create k8s client.
-func NewKubeConfigClient(kubeConfig []byte) (kubernetes.Interface, error) {
-	c, err := clientcmd.RESTConfigFromKubeConfig(kubeConfig)
+// NewDynamicClient return the dynamic client of the first resolved source, see NewRestConfig.
+func NewDynamicClient(config *KubeAPIConfig, opts ...Option) (dynamic.Interface, error) {
+	restConfig, _, err := NewRestConfig(config, opts...)
 	if err != nil {
 		return nil, err
 	}
-	clientSet, err := kubernetes.NewForConfig(c)
+	return dynamic.NewForConfig(forCRDClient(restConfig))
+}
+
+// NewIstioClient return the istio client of the first resolved source, see NewRestConfig.
+func NewIstioClient(config *KubeAPIConfig, opts ...Option) (istio.Interface, error) {
+	restConfig, _, err := NewRestConfig(config, opts...)
 	if err != nil {
 		return nil, err
 	}
-	return clientSet, nil
+	return istio.NewForConfig(forCRDClient(restConfig))
 }
 
-func NewDynamicConfigClient(kubeConfig []byte) (dynamic.Interface, error) {
-	c, err := clientcmd.RESTConfigFromKubeConfig(kubeConfig)
+// NewKubeConfigClient return the k8s client of the kubeconfig content, see NewRestConfigFromBytes.
+func NewKubeConfigClient(kubeConfig []byte, opts ...Option) (kubernetes.Interface, error) {
+	restConfig, err := NewRestConfigFromBytes(kubeConfig, opts...)
 	if err != nil {
 		return nil, err
 	}
-	dynamicClient, err := dynamic.NewForConfig(c)
+	return kubernetes.NewForConfig(forKubeClient(restConfig))
+}
+
+// NewDynamicConfigClient return the dynamic client of the kubeconfig content, see NewRestConfigFromBytes.
+func NewDynamicConfigClient(kubeConfig []byte, opts ...Option) (dynamic.Interface, error) {
+	restConfig, err := NewRestConfigFromBytes(kubeConfig, opts...)
 	if err != nil {
 		return nil, err
 	}
-	return dynamicClient, nil
+	return dynamic.NewForConfig(forCRDClient(restConfig))
 }
 
-func NewIstioConfigClient(kubeConfig []byte) (istio.Interface, error) {
-	c, err := clientcmd.RESTConfigFromKubeConfig(kubeConfig)
+// NewIstioConfigClient return the istio client of the kubeconfig content, see NewRestConfigFromBytes.
+func NewIstioConfigClient(kubeConfig []byte, opts ...Option) (istio.Interface, error) {
+	restConfig, err := NewRestConfigFromBytes(kubeConfig, opts...)
 	if err != nil {
 		return nil, err
 	}
-	return istio.NewForConfigOrDie(c), nil
+	return istio.NewForConfig(forCRDClient(restConfig))
 }
 
 func (c *ManagerClient) KubeClient() kubernetes.Interface {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.kubeClient
 }
 
 func (c *ManagerClient) DynamicClient() dynamic.Interface {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.dynamicClient
 }
 
 func (c *ManagerClient) IstioClient() istio.Interface {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.istioClient
 }
+
+// MetadataClient return the client of the metadata of any kind, such as for the metadata only informers.
+func (c *ManagerClient) MetadataClient() metadata.Interface {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.metadataClient
+}
+
+// Discovery return the cached discovery of the k8s apiserver, it is replaced by Reload.
+func (c *ManagerClient) Discovery() *Discovery {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.discovery
+}
+
+// Namespace return the default namespace of the kubeconfig context, the Namespace override
+// or the namespace of the pod running ggp.
+func (c *ManagerClient) Namespace() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.namespace
+}
+
+// ConfigSource return where the rest config of the clients is loaded from.
+func (c *ManagerClient) ConfigSource() ConfigSource {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.source
+}
@@ -0,0 +1,268 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/pflag"
+	"io"
+	"k8s.io/apimachinery/pkg/runtime"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sigs.k8s.io/yaml"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// writeMockKubeConfig write a kubeconfig with one cluster, user and context named name.
+func writeMockKubeConfig(t *testing.T, name, server string) string {
+	path := filepath.Join(t.TempDir(), name)
+	content := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: %[1]s
+  cluster:
+    server: %[2]s
+users:
+- name: %[1]s
+  user:
+    token: %[1]s-token
+contexts:
+- name: %[1]s
+  context:
+    cluster: %[1]s
+    user: %[1]s
+    namespace: %[1]s-ns
+current-context: %[1]s
+`, name, server)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadRestConfig(t *testing.T) {
+	edge := writeMockKubeConfig(t, "edge", "https://edge:6443")
+	cloud := writeMockKubeConfig(t, "cloud", "https://cloud:6443")
+	paths := strings.Join([]string{edge, cloud}, string(filepath.ListSeparator))
+
+	tests := []struct {
+		name          string
+		config        KubeAPIConfig
+		env           string
+		wantHost      string
+		wantToken     string
+		wantNamespace string
+		wantSource    ConfigSource
+	}{
+		{"current context", KubeAPIConfig{KubeConfig: paths}, "", "https://edge:6443", "edge-token", "edge-ns", ConfigSourceFlags},
+		{"context", KubeAPIConfig{KubeConfig: paths, Context: "cloud"}, "", "https://cloud:6443", "cloud-token", "cloud-ns", ConfigSourceFlags},
+		{"cluster and namespace", KubeAPIConfig{KubeConfig: paths, Cluster: "cloud", Namespace: "mqtt"}, "", "https://cloud:6443", "edge-token", "mqtt", ConfigSourceFlags},
+		{"auth info", KubeAPIConfig{KubeConfig: paths, AuthInfo: "cloud"}, "", "https://edge:6443", "cloud-token", "edge-ns", ConfigSourceFlags},
+		{"master", KubeAPIConfig{KubeConfig: edge, Master: "https://master:6443"}, "", "https://master:6443", "edge-token", "edge-ns", ConfigSourceFlags},
+		{"env", KubeAPIConfig{Context: "cloud"}, paths, "https://cloud:6443", "cloud-token", "cloud-ns", ConfigSourceEnv},
+	}
+	env, ok := os.LookupEnv("KUBECONFIG")
+	defer func() {
+		if ok {
+			os.Setenv("KUBECONFIG", env)
+		} else {
+			os.Unsetenv("KUBECONFIG")
+		}
+	}()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("KUBECONFIG", tt.env)
+			restConfig, namespace, source, err := loadRestConfig(&tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if restConfig.Host != tt.wantHost || restConfig.BearerToken != tt.wantToken {
+				t.Errorf("loadRestConfig() = %s %s, want %s %s", restConfig.Host, restConfig.BearerToken, tt.wantHost, tt.wantToken)
+			}
+			if namespace != tt.wantNamespace || source != tt.wantSource {
+				t.Errorf("loadRestConfig() = %s %s, want %s %s", namespace, source, tt.wantNamespace, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := writeMockKubeConfig(t, "edge", "https://edge:6443")
+	config := NewConfig()
+	config.KubeAPIConfig.KubeConfig = path
+	c, err := NewManagerClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded := make(chan struct{}, 1)
+	remove := c.OnReload(func() { reloaded <- struct{}{} })
+	defer remove()
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	if err := c.Watch(stopCh, 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	kubeClient := c.KubeClient()
+	content, err := os.ReadFile(writeMockKubeConfig(t, "cloud", "https://cloud:6443"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-reloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch() did not reload the changed kubeconfig")
+	}
+	if c.KubeClient() == kubeClient || c.Namespace() != "cloud-ns" {
+		t.Errorf("Reload() kept the clients of the old kubeconfig, namespace %s", c.Namespace())
+	}
+
+	if err := (&ManagerClient{}).Reload(); !errors.Is(err, ErrNotReloadable) {
+		t.Errorf("Reload() error = %v, want ErrNotReloadable", err)
+	}
+}
+
+func TestReloadSerialized(t *testing.T) {
+	config := NewConfig()
+	config.KubeAPIConfig.KubeConfig = writeMockKubeConfig(t, "edge", "https://edge:6443")
+	c, err := NewManagerClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var running, overlapped int32
+	remove := c.OnReload(func() {
+		if atomic.AddInt32(&running, 1) > 1 {
+			atomic.StoreInt32(&overlapped, 1)
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&running, -1)
+	})
+	defer remove()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.Reload(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if atomic.LoadInt32(&overlapped) != 0 {
+		t.Errorf("Reload() called OnReload functions of two reloads at once")
+	}
+}
+
+// pflagParse parse args with the flags of AddFlags.
+func pflagParse(args ...string) error {
+	fs := pflag.NewFlagSet("ggp", pflag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	AddFlags(fs)
+	return fs.Parse(args)
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ggp.yaml")
+	content := "kubeAPIConfig:\n  master: https://file:6443\n  qps: 10\n  burst: 20\n  kubeConfig: /file/config\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("GGP_KUBE_QPS", "30")
+	os.Setenv("GGP_KUBE_BURST", "40")
+	defer os.Unsetenv("GGP_KUBE_QPS")
+	defer os.Unsetenv("GGP_KUBE_BURST")
+
+	fs := pflag.NewFlagSet("ggp", pflag.ContinueOnError)
+	AddFlags(fs)
+	if err := fs.Parse([]string{"--config", path, "--kube-burst", "50", "--kube-context", "edge"}); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfigFromFlags(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := KubeAPIConfig{
+		Master:      "https://file:6443",
+		ContentType: runtime.ContentTypeProtobuf,
+		QPS:         30,
+		Burst:       50,
+		KubeConfig:  "/file/config",
+		Context:     "edge",
+	}
+	if !reflect.DeepEqual(*config.KubeAPIConfig, want) {
+		t.Errorf("LoadConfigFromFlags() = %+v, want %+v", *config.KubeAPIConfig, want)
+	}
+	if typ := fs.Lookup("kube-qps").Value.Type(); typ != "int32" {
+		t.Errorf("--kube-qps is a %s flag, want int32", typ)
+	}
+	if err := pflagParse("--kube-burst", "many"); err == nil {
+		t.Errorf("--kube-burst many parsed, want an int32 error")
+	}
+
+	for _, invalid := range []KubeAPIConfig{
+		{QPS: -1},
+		{QPS: 10, Burst: 5},
+		{ContentType: "text/plain"},
+	} {
+		if err := (&Config{KubeAPIConfig: &invalid}).Validate(); err == nil {
+			t.Errorf("Validate() of %+v succeeded, want an error", invalid)
+		}
+	}
+
+	data, err := DefaultConfigYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config = &Config{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil || !reflect.DeepEqual(config, NewConfig()) {
+		t.Errorf("DefaultConfigYAML() = %s, want the defaults", data)
+	}
+}
+
+func TestNewRestConfigFromBytes(t *testing.T) {
+	kubeConfig, err := os.ReadFile(writeMockKubeConfig(t, "edge", "https://edge:6443"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	restConfig, err := NewRestConfigFromBytes(kubeConfig, WithTimeout(time.Second), WithUserAgent("ggp"), WithInsecureSkipTLSVerify())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restConfig.QPS != DefaultKubeQPS || restConfig.Burst != DefaultKubeBurst || restConfig.ContentType != runtime.ContentTypeProtobuf {
+		t.Errorf("NewRestConfigFromBytes() = %v %v %s, want the defaults", restConfig.QPS, restConfig.Burst, restConfig.ContentType)
+	}
+	if restConfig.Timeout != time.Second || restConfig.UserAgent != "ggp" || !restConfig.Insecure {
+		t.Errorf("NewRestConfigFromBytes() did not apply the options")
+	}
+
+	if _, err := NewIstioConfigClient([]byte("invalid")); err == nil {
+		t.Errorf("NewIstioConfigClient() of an invalid kubeconfig succeeded, want an error")
+	}
+	if _, err := NewKubeClient(&KubeAPIConfig{KubeConfig: filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Errorf("NewKubeClient() of a missing kubeconfig succeeded, want an error")
+	}
+}
@@ -16,6 +16,19 @@ limitations under the License.
 
 package client
 
+import (
+	"fmt"
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"os"
+	"sigs.k8s.io/yaml"
+	"strconv"
+)
+
+// ConfigFlag is the flag of the config file path, see AddFlags.
+const ConfigFlag = "config"
+
 type Config struct {
 	// KubeAPIConfig indicates the kubernetes cluster info which gateway will connect.
 	// +Required
@@ -29,3 +42,160 @@ func NewConfig() *Config {
 		KubeAPIConfig: NewKubeAPIConfig(),
 	}
 }
+
+// configField is a KubeAPIConfig field settable from the environment and the flags.
+type configField struct {
+	// flag is the flag name, such as kube-qps.
+	flag string
+	// env is the environment variable name, such as GGP_KUBE_QPS.
+	env   string
+	usage string
+	// addFlag add the flag of the field typed as the field, defaulting to the value in defaults.
+	addFlag func(fs *pflag.FlagSet, defaults *KubeAPIConfig)
+	// set parse and set the field value, from the environment or the flag value.
+	set func(c *KubeAPIConfig, value string) error
+}
+
+// stringField return the configField of a string field.
+func stringField(flag, env, usage string, field func(c *KubeAPIConfig) *string) configField {
+	return configField{
+		flag:  flag,
+		env:   env,
+		usage: usage,
+		addFlag: func(fs *pflag.FlagSet, defaults *KubeAPIConfig) {
+			fs.String(flag, *field(defaults), usage)
+		},
+		set: func(c *KubeAPIConfig, value string) error {
+			*field(c) = value
+			return nil
+		},
+	}
+}
+
+// int32Field return the configField of an int32 field.
+func int32Field(flag, env, usage string, field func(c *KubeAPIConfig) *int32) configField {
+	return configField{
+		flag:  flag,
+		env:   env,
+		usage: usage,
+		addFlag: func(fs *pflag.FlagSet, defaults *KubeAPIConfig) {
+			fs.Int32(flag, *field(defaults), usage)
+		},
+		set: func(c *KubeAPIConfig, value string) error {
+			v, err := strconv.ParseInt(value, 10, 32)
+			if err != nil {
+				return err
+			}
+			*field(c) = int32(v)
+			return nil
+		},
+	}
+}
+
+// configFields is the fields of KubeAPIConfig settable from the environment and the flags.
+var configFields = []configField{
+	stringField("kube-master", "GGP_KUBE_MASTER", "The address of the Kubernetes API server, overrides any value in the kubeconfig.",
+		func(c *KubeAPIConfig) *string { return &c.Master }),
+	stringField("kube-content-type", "GGP_KUBE_CONTENT_TYPE", "The content type of the requests sent to the Kubernetes API server.",
+		func(c *KubeAPIConfig) *string { return &c.ContentType }),
+	int32Field("kube-qps", "GGP_KUBE_QPS", "The QPS to use while talking with the Kubernetes API server.",
+		func(c *KubeAPIConfig) *int32 { return &c.QPS }),
+	int32Field("kube-burst", "GGP_KUBE_BURST", "The burst to use while talking with the Kubernetes API server.",
+		func(c *KubeAPIConfig) *int32 { return &c.Burst }),
+	stringField("kubeconfig", "GGP_KUBECONFIG", "The paths to the kubeconfig files, separated like $KUBECONFIG.",
+		func(c *KubeAPIConfig) *string { return &c.KubeConfig }),
+	stringField("kube-context", "GGP_KUBE_CONTEXT", "The kubeconfig context to use instead of the current context.",
+		func(c *KubeAPIConfig) *string { return &c.Context }),
+	stringField("kube-cluster", "GGP_KUBE_CLUSTER", "The kubeconfig cluster to use instead of the one of the context.",
+		func(c *KubeAPIConfig) *string { return &c.Cluster }),
+	stringField("kube-auth-info", "GGP_KUBE_AUTH_INFO", "The kubeconfig user to use instead of the one of the context.",
+		func(c *KubeAPIConfig) *string { return &c.AuthInfo }),
+	stringField("kube-namespace", "GGP_KUBE_NAMESPACE", "The default namespace instead of the one of the context.",
+		func(c *KubeAPIConfig) *string { return &c.Namespace }),
+}
+
+// AddFlags add the --config flag and the flags of every KubeAPIConfig field to fs, see LoadConfigFromFlags.
+func AddFlags(fs *pflag.FlagSet) {
+	defaults := NewKubeAPIConfig()
+	fs.String(ConfigFlag, "", "The path to the YAML or JSON config file.")
+	for _, field := range configFields {
+		field.addFlag(fs, defaults)
+	}
+}
+
+// LoadConfig return the config of the YAML or JSON file at path, the defaults if path is empty,
+// overridden by the GGP_* environment variables, such as GGP_KUBECONFIG and GGP_KUBE_QPS.
+func LoadConfig(path string) (*Config, error) {
+	return loadConfig(path, nil)
+}
+
+// LoadConfigFromFlags return the config of the file of the --config flag overridden by the GGP_*
+// environment variables, then by the flags set on the command line. fs must be set up by AddFlags and parsed.
+func LoadConfigFromFlags(fs *pflag.FlagSet) (*Config, error) {
+	path, err := fs.GetString(ConfigFlag)
+	if err != nil {
+		return nil, err
+	}
+	return loadConfig(path, fs)
+}
+
+// loadConfig load the defaults, the file, the environment and the changed flags of fs in order, then validate.
+func loadConfig(path string, fs *pflag.FlagSet) (*Config, error) {
+	c := NewConfig()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.UnmarshalStrict(data, c); err != nil {
+			return nil, fmt.Errorf("config file %s: %v", path, err)
+		}
+		if c.KubeAPIConfig == nil {
+			c.KubeAPIConfig = NewKubeAPIConfig()
+		}
+	}
+	for _, field := range configFields {
+		if value, ok := os.LookupEnv(field.env); ok {
+			if err := field.set(c.KubeAPIConfig, value); err != nil {
+				return nil, fmt.Errorf("environment %s: %v", field.env, err)
+			}
+		}
+		if fs != nil && fs.Changed(field.flag) {
+			// the flag value is already parsed as the field type, its string form is parsed back by set.
+			if err := field.set(c.KubeAPIConfig, fs.Lookup(field.flag).Value.String()); err != nil {
+				return nil, fmt.Errorf("flag --%s: %v", field.flag, err)
+			}
+		}
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate return the errors of the config, such as a negative QPS, a burst lower than QPS
+// or a ContentType other than JSON and protobuf.
+func (c *Config) Validate() error {
+	if c.KubeAPIConfig == nil {
+		return fmt.Errorf("kubeAPIConfig is required")
+	}
+	var errs []error
+	kube := c.KubeAPIConfig
+	if kube.QPS < 0 {
+		errs = append(errs, fmt.Errorf("kubeAPIConfig.qps %d must not be negative", kube.QPS))
+	}
+	if kube.Burst < kube.QPS {
+		errs = append(errs, fmt.Errorf("kubeAPIConfig.burst %d must not be lower than qps %d", kube.Burst, kube.QPS))
+	}
+	switch kube.ContentType {
+	case "", runtime.ContentTypeJSON, runtime.ContentTypeProtobuf:
+	default:
+		errs = append(errs, fmt.Errorf("kubeAPIConfig.contentType %q must be %s or %s", kube.ContentType, runtime.ContentTypeJSON, runtime.ContentTypeProtobuf))
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// DefaultConfigYAML return the default config file, such as written by ggp to start from.
+func DefaultConfigYAML() ([]byte, error) {
+	return yaml.Marshal(NewConfig())
+}
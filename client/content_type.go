@@ -0,0 +1,48 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+)
+
+// kubeContentTypes is the accepted content types of the built-in clients, protobuf first then JSON,
+// for the resources served by aggregated apiservers without protobuf support.
+const kubeContentTypes = runtime.ContentTypeProtobuf + "," + runtime.ContentTypeJSON
+
+// forKubeClient return a copy of restConfig for the built-in k8s client.
+// It speaks protobuf with a JSON fallback unless the content type is set to JSON.
+func forKubeClient(restConfig *rest.Config) *rest.Config {
+	restConfig = rest.CopyConfig(restConfig)
+	if restConfig.ContentType == "" {
+		restConfig.ContentType = runtime.ContentTypeProtobuf
+	}
+	if restConfig.ContentType == runtime.ContentTypeProtobuf && restConfig.AcceptContentTypes == "" {
+		restConfig.AcceptContentTypes = kubeContentTypes
+	}
+	return restConfig
+}
+
+// forCRDClient return a copy of restConfig for the dynamic and istio clients.
+// The custom resources are only served as JSON, whatever the content type of the config.
+func forCRDClient(restConfig *rest.Config) *rest.Config {
+	restConfig = rest.CopyConfig(restConfig)
+	restConfig.ContentType = runtime.ContentTypeJSON
+	restConfig.AcceptContentTypes = runtime.ContentTypeJSON
+	return restConfig
+}
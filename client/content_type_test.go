@@ -0,0 +1,144 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newMockPodList return a pod list with n pods shaped like the pods of a deployment.
+func newMockPodList(n int) *corev1.PodList {
+	list := &corev1.PodList{}
+	for i := 0; i < n; i++ {
+		list.Items = append(list.Items, corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        fmt.Sprintf("mqtt-%d", i),
+				Namespace:   "edge",
+				UID:         "6f1c3d2e-7a5b-4c9d-8e0f-1a2b3c4d5e6f",
+				Labels:      map[string]string{"app": "mqtt", "pod-template-hash": "5d8f7c9b6"},
+				Annotations: map[string]string{"sidecar.istio.io/status": strings.Repeat("x", 256)},
+			},
+			Spec: corev1.PodSpec{
+				NodeName: "edge-node-1",
+				Containers: []corev1.Container{{
+					Name:  "mqtt",
+					Image: "eclipse-mosquitto:2.0",
+					Ports: []corev1.ContainerPort{{Name: "mqtt", ContainerPort: 1883}},
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+					},
+				}},
+			},
+			Status: corev1.PodStatus{
+				Phase:  corev1.PodRunning,
+				PodIP:  "10.244.1.10",
+				HostIP: "192.168.1.10",
+			},
+		})
+	}
+	return list
+}
+
+// newMockPodServer return an apiserver serving list as protobuf or JSON following the Accept header,
+// accepts records the Accept header of every request.
+func newMockPodServer(t testing.TB, list *corev1.PodList) (server *httptest.Server, accepts func() []string) {
+	bodies := map[string][]byte{}
+	for _, mediaType := range []string{runtime.ContentTypeJSON, runtime.ContentTypeProtobuf} {
+		info, _ := runtime.SerializerInfoForMediaType(scheme.Codecs.SupportedMediaTypes(), mediaType)
+		body, err := runtime.Encode(scheme.Codecs.EncoderForVersion(info.Serializer, corev1.SchemeGroupVersion), list)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bodies[mediaType] = body
+	}
+	var mu sync.Mutex
+	var headers []string
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept := r.Header.Get("Accept")
+		mu.Lock()
+		headers = append(headers, accept)
+		mu.Unlock()
+		mediaType := runtime.ContentTypeJSON
+		if strings.HasPrefix(accept, runtime.ContentTypeProtobuf) {
+			mediaType = runtime.ContentTypeProtobuf
+		}
+		w.Header().Set("Content-Type", mediaType)
+		w.Write(bodies[mediaType])
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, headers...)
+	}
+}
+
+func TestContentType(t *testing.T) {
+	server, accepts := newMockPodServer(t, newMockPodList(1))
+	restConfig := buildRestConfig(&rest.Config{Host: server.URL}, NewKubeAPIConfig(), nil)
+	c, err := newManagerClient(restConfig, "", ConfigSourceBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.KubeClient().CoreV1().Pods("edge").List(context.Background(), metav1.ListOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// the dynamic client stands for the custom resources, the pods are served as JSON as well.
+	pods := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	if _, err := c.DynamicClient().Resource(pods).Namespace("edge").List(context.Background(), metav1.ListOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := accepts(); len(got) != 2 || got[0] != kubeContentTypes || got[1] != runtime.ContentTypeJSON {
+		t.Errorf("Accept headers = %v, want %s then %s", got, kubeContentTypes, runtime.ContentTypeJSON)
+	}
+}
+
+// BenchmarkListPods compares listing 500 pods as JSON and as protobuf, see -benchmem for the memory.
+func BenchmarkListPods(b *testing.B) {
+	server, _ := newMockPodServer(b, newMockPodList(500))
+	for name, contentType := range map[string]string{"json": runtime.ContentTypeJSON, "protobuf": runtime.ContentTypeProtobuf} {
+		contentType := contentType
+		b.Run(name, func(b *testing.B) {
+			restConfig := forKubeClient(buildRestConfig(&rest.Config{Host: server.URL}, NewKubeAPIConfig(), []Option{WithContentType(contentType)}))
+			kubeClient, err := kubernetes.NewForConfig(restConfig)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := kubeClient.CoreV1().Pods("edge").List(context.Background(), metav1.ListOptions{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/restmapper"
+	"sync"
+)
+
+// Discovery is the cached discovery of the k8s apiserver, it is filled at the first call
+// and kept until Invalidate, such as after the apiserver is upgraded.
+type Discovery struct {
+	client discovery.CachedDiscoveryInterface
+	mapper *restmapper.DeferredDiscoveryRESTMapper
+	// mu guards version.
+	mu      sync.Mutex
+	version *version.Info
+}
+
+// NewDiscovery return the cached discovery of client.
+func NewDiscovery(client discovery.DiscoveryInterface) *Discovery {
+	cached := memory.NewMemCacheClient(client)
+	return &Discovery{
+		client: cached,
+		mapper: restmapper.NewDeferredDiscoveryRESTMapper(cached),
+	}
+}
+
+// DiscoveryClient return the cached discovery client.
+func (d *Discovery) DiscoveryClient() discovery.CachedDiscoveryInterface {
+	return d.client
+}
+
+// ServerVersion return the version of the k8s apiserver, it is requested once until Invalidate.
+func (d *Discovery) ServerVersion() (*version.Info, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.version != nil {
+		return d.version, nil
+	}
+	info, err := d.client.ServerVersion()
+	if err != nil {
+		return nil, err
+	}
+	d.version = info
+	return info, nil
+}
+
+// ServerGroupVersions return the group versions served by the k8s apiserver, such as networking.k8s.io/v1.
+func (d *Discovery) ServerGroupVersions() ([]schema.GroupVersion, error) {
+	groups, err := d.client.ServerGroups()
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]schema.GroupVersion, 0)
+	for _, group := range groups.Groups {
+		for _, v := range group.Versions {
+			ret = append(ret, schema.GroupVersion{Group: group.Name, Version: v.Version})
+		}
+	}
+	return ret, nil
+}
+
+// IsServed return true if the k8s apiserver serves the resource in the group version,
+// such as ingresses in networking.k8s.io/v1 which only serves networkpolicies before k8s 1.19.
+func (d *Discovery) IsServed(gvr schema.GroupVersionResource) (bool, error) {
+	resources, err := d.client.ServerResourcesForGroupVersion(gvr.GroupVersion().String())
+	if err != nil {
+		if errors.Is(err, memory.ErrCacheNotFound) || apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	for _, resource := range resources.APIResources {
+		if resource.Name == gvr.Resource {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RESTMapper return the rest mapper filled from the discovery at the first mapping,
+// it is reset and filled again when a kind is not found.
+func (d *Discovery) RESTMapper() meta.RESTMapper {
+	return d.mapper
+}
+
+// Invalidate drop the cached server version, group versions and mappings.
+func (d *Discovery) Invalidate() {
+	d.mu.Lock()
+	d.version = nil
+	d.mu.Unlock()
+	d.client.Invalidate()
+	d.mapper.Reset()
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+)
+
+func TestDiscovery(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	clientset.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "networking.k8s.io/v1", APIResources: []metav1.APIResource{{Name: "networkpolicies", Kind: "NetworkPolicy", Namespaced: true}}},
+		{GroupVersion: "extensions/v1beta1", APIResources: []metav1.APIResource{{Name: "ingresses", Kind: "Ingress", Namespaced: true}}},
+	}
+	d := NewDiscovery(clientset.Discovery())
+
+	if _, err := d.ServerVersion(); err != nil {
+		t.Fatal(err)
+	}
+	gvs, err := d.ServerGroupVersions()
+	if err != nil || len(gvs) != 2 {
+		t.Errorf("ServerGroupVersions() = %v, %v, want 2 group versions", gvs, err)
+	}
+	for gvr, want := range map[schema.GroupVersionResource]bool{
+		{Group: "networking.k8s.io", Version: "v1", Resource: "ingresses"}:          false,
+		{Group: "extensions", Version: "v1beta1", Resource: "ingresses"}:            true,
+		{Group: "autoscaling", Version: "v2", Resource: "horizontalpodautoscalers"}: false,
+	} {
+		if got, err := d.IsServed(gvr); err != nil || got != want {
+			t.Errorf("IsServed(%s) = %v, %v, want %v", gvr, got, err, want)
+		}
+	}
+	mapping, err := d.RESTMapper().RESTMapping(schema.GroupKind{Group: "extensions", Kind: "Ingress"})
+	if err != nil || mapping.Resource.Resource != "ingresses" {
+		t.Errorf("RESTMapping() = %v, %v, want ingresses", mapping, err)
+	}
+}
@@ -16,7 +16,12 @@ limitations under the License.
 
 package client
 
-import "k8s.io/apimachinery/pkg/runtime"
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"path/filepath"
+)
 
 const (
 	// DefaultKubeQPS return default kubernetes qps.
@@ -33,6 +38,8 @@ type KubeAPIConfig struct {
 	// Note: Can not use "omitempty" option,  It will affect the output of the default configuration file
 	Master string `json:"master"`
 	// ContentType indicates the ContentType of message transmission when interacting with k8s
+	// It applies to the built-in k8s client only, falling back to JSON for the resources not served as protobuf,
+	// the dynamic and istio clients always use JSON as the custom resources do not support protobuf.
 	// default "application/vnd.kubernetes.protobuf"
 	ContentType string `json:"contentType,omitempty"`
 	// QPS to while talking with kubernetes apiserve
@@ -42,18 +49,59 @@ type KubeAPIConfig struct {
 	// default 200
 	Burst int32 `json:"burst,omitempty"`
 	// KubeConfig indicates the path to kubeConfig file with authorization and master location information.
+	// Several paths are separated like $KUBECONFIG, such as /root/.kube/config:/root/.kube/edge,
+	// they are merged and the first file setting a value wins.
 	// default "/root/.kube/config"
 	// +Required
 	KubeConfig string `json:"kubeConfig"`
+	// Context indicates the kubeConfig context to use instead of the current context.
+	// default ""
+	Context string `json:"context,omitempty"`
+	// Cluster indicates the kubeConfig cluster to use instead of the one of the context.
+	// default ""
+	Cluster string `json:"cluster,omitempty"`
+	// AuthInfo indicates the kubeConfig user to use instead of the one of the context.
+	// default ""
+	AuthInfo string `json:"authInfo,omitempty"`
+	// Namespace indicates the default namespace instead of the one of the context.
+	// default ""
+	Namespace string `json:"namespace,omitempty"`
 }
 
 // NewKubeAPIConfig return default kubernetes api config.
 func NewKubeAPIConfig() *KubeAPIConfig {
 	return &KubeAPIConfig{
 		Master:      "",
-		ContentType: runtime.ContentTypeJSON, // often use runtime.ContentTypeProtobuf.
+		ContentType: runtime.ContentTypeProtobuf,
 		QPS:         DefaultKubeQPS,
 		Burst:       DefaultKubeBurst,
 		KubeConfig:  "",
 	}
 }
+
+// loadingRules return the loading rules of KubeConfig, a single path must exist.
+func (c *KubeAPIConfig) loadingRules() *clientcmd.ClientConfigLoadingRules {
+	paths := filepath.SplitList(c.KubeConfig)
+	if len(paths) == 1 {
+		return &clientcmd.ClientConfigLoadingRules{ExplicitPath: paths[0]}
+	}
+	return &clientcmd.ClientConfigLoadingRules{Precedence: paths}
+}
+
+// overrides return the overrides of Master, Context, Cluster, AuthInfo and Namespace.
+func (c *KubeAPIConfig) overrides() *clientcmd.ConfigOverrides {
+	return &clientcmd.ConfigOverrides{
+		ClusterInfo:    clientcmdapi.Cluster{Server: c.Master},
+		CurrentContext: c.Context,
+		Context: clientcmdapi.Context{
+			Cluster:   c.Cluster,
+			AuthInfo:  c.AuthInfo,
+			Namespace: c.Namespace,
+		},
+	}
+}
+
+// clientConfig return the client config loaded by rules with the overrides applied.
+func (c *KubeAPIConfig) clientConfig(rules *clientcmd.ClientConfigLoadingRules) clientcmd.ClientConfig {
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, c.overrides())
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"k8s.io/client-go/rest"
+	"time"
+)
+
+// Option configures the rest config of the clients, it is applied after the KubeAPIConfig settings.
+type Option func(*rest.Config)
+
+// WithTimeout set the timeout of every request, 0 means no timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *rest.Config) {
+		c.Timeout = timeout
+	}
+}
+
+// WithUserAgent set the user agent of every request, such as the name of the component using ggp.
+func WithUserAgent(userAgent string) Option {
+	return func(c *rest.Config) {
+		c.UserAgent = userAgent
+	}
+}
+
+// WithRateLimit set the QPS and the burst of the requests, overrides KubeAPIConfig.QPS and Burst.
+func WithRateLimit(qps float32, burst int) Option {
+	return func(c *rest.Config) {
+		c.QPS = qps
+		c.Burst = burst
+	}
+}
+
+// WithContentType set the content type of the requests of the built-in k8s client, overrides KubeAPIConfig.ContentType.
+func WithContentType(contentType string) Option {
+	return func(c *rest.Config) {
+		c.ContentType = contentType
+	}
+}
+
+// WithTLSServerName set the server name checked against the certificate of the k8s apiserver.
+func WithTLSServerName(serverName string) Option {
+	return func(c *rest.Config) {
+		c.TLSClientConfig.ServerName = serverName
+	}
+}
+
+// WithCAData trust the PEM encoded certificate authorities instead of the ones of the kubeconfig.
+func WithCAData(caData []byte) Option {
+	return func(c *rest.Config) {
+		c.TLSClientConfig.CAFile = ""
+		c.TLSClientConfig.CAData = caData
+		c.TLSClientConfig.Insecure = false
+	}
+}
+
+// WithInsecureSkipTLSVerify skip the verification of the certificate of the k8s apiserver, for tests only.
+func WithInsecureSkipTLSVerify() Option {
+	return func(c *rest.Config) {
+		c.TLSClientConfig.CAFile = ""
+		c.TLSClientConfig.CAData = nil
+		c.TLSClientConfig.Insecure = true
+	}
+}
+
+// buildRestConfig return a copy of base with the client settings of config then opts applied,
+// every client is built from a rest config returned by it.
+func buildRestConfig(base *rest.Config, config *KubeAPIConfig, opts []Option) *rest.Config {
+	restConfig := rest.CopyConfig(base)
+	restConfig.QPS = float32(config.QPS)
+	restConfig.Burst = int(config.Burst)
+	restConfig.ContentType = config.ContentType
+	for _, opt := range opts {
+		opt(restConfig)
+	}
+	return restConfig
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultWatchPeriod is default period Watch checks the kubeconfig and token files.
+const DefaultWatchPeriod = time.Second * 10
+
+// ErrNotReloadable is returned by Reload for the clients of NewManagerConfigClient.
+var ErrNotReloadable = errors.New("client is not loaded from files")
+
+// watchedFiles return the kubeconfig files of the source and the token and certificate files of restConfig.
+func watchedFiles(config *KubeAPIConfig, restConfig *rest.Config, source ConfigSource) []string {
+	var files []string
+	switch source {
+	case ConfigSourceFlags:
+		files = append(files, filepath.SplitList(config.KubeConfig)...)
+	case ConfigSourceEnv:
+		files = append(files, filepath.SplitList(os.Getenv(clientcmd.RecommendedConfigPathEnvVar))...)
+	}
+	for _, file := range []string{
+		restConfig.BearerTokenFile,
+		restConfig.TLSClientConfig.CAFile,
+		restConfig.TLSClientConfig.CertFile,
+		restConfig.TLSClientConfig.KeyFile,
+	} {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// fingerprint return the hash of the content of files, missing files are hashed as such.
+func fingerprint(files []string) string {
+	h := sha256.New()
+	for _, file := range files {
+		h.Write([]byte(file))
+		data, err := os.ReadFile(file)
+		if err != nil {
+			h.Write([]byte{0})
+			continue
+		}
+		h.Write([]byte{1})
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Files return the kubeconfig, token and certificate files the clients are loaded from.
+func (c *ManagerClient) Files() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]string{}, c.files...)
+}
+
+// Reload load the config again and replace the kube, dynamic, istio and metadata clients and the discovery at once,
+// then call the functions registered by OnReload. The clients are kept if loading fails.
+// Concurrent reloads, such as Watch and an explicit call, run one after the other.
+func (c *ManagerClient) Reload() error {
+	if c.config == nil {
+		return ErrNotReloadable
+	}
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+	loaded, err := loadManagerClient(c.config, c.opts)
+	if err != nil {
+		return fmt.Errorf("reload clients: %v", err)
+	}
+	c.mu.Lock()
+	c.kubeClient = loaded.kubeClient
+	c.dynamicClient = loaded.dynamicClient
+	c.istioClient = loaded.istioClient
+	c.metadataClient = loaded.metadataClient
+	c.discovery = loaded.discovery
+	c.namespace = loaded.namespace
+	c.source = loaded.source
+	c.files = loaded.files
+	c.mu.Unlock()
+
+	c.reloadsMu.Lock()
+	reloads := make([]func(), 0, len(c.reloads))
+	for _, fn := range c.reloads {
+		reloads = append(reloads, fn)
+	}
+	c.reloadsMu.Unlock()
+	for _, fn := range reloads {
+		fn()
+	}
+	return nil
+}
+
+// OnReload register fn to be called after the clients are reloaded, such as to restart the informers.
+// The returned function unregisters fn.
+func (c *ManagerClient) OnReload(fn func()) func() {
+	c.reloadsMu.Lock()
+	defer c.reloadsMu.Unlock()
+	if c.reloads == nil {
+		c.reloads = map[int]func(){}
+	}
+	id := c.nextID
+	c.nextID++
+	c.reloads[id] = fn
+	return func() {
+		c.reloadsMu.Lock()
+		defer c.reloadsMu.Unlock()
+		delete(c.reloads, id)
+	}
+}
+
+// Watch check the files of the clients every period until stopCh is closed, and Reload once they change,
+// such as after a sidecar rotates the token. Default DefaultWatchPeriod if period is not positive.
+// Files replaced through symlinks, like the mounted service account, are seen as they are read by content.
+func (c *ManagerClient) Watch(stopCh <-chan struct{}, period time.Duration) error {
+	if c.config == nil {
+		return ErrNotReloadable
+	}
+	if period <= 0 {
+		period = DefaultWatchPeriod
+	}
+	last := fingerprint(c.Files())
+	go wait.Until(func() {
+		current := fingerprint(c.Files())
+		if current == last {
+			return
+		}
+		if err := c.Reload(); err != nil {
+			// keep the last fingerprint, a half written file is loaded again at the next check.
+			utilruntime.HandleError(err)
+			return
+		}
+		last = fingerprint(c.Files())
+	}, period, stopCh)
+	return nil
+}
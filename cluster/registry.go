@@ -0,0 +1,274 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	"sort"
+	"strings"
+	"sync"
+	"x6t.io/ggp"
+	"x6t.io/ggp/client"
+	"x6t.io/ggp/workload"
+)
+
+var (
+	// ErrClusterExists is returned when adding a cluster under a registered name.
+	ErrClusterExists = errors.New("cluster already registered")
+	// ErrClusterNotFound is returned when the cluster name is not registered.
+	ErrClusterNotFound = errors.New("cluster not registered")
+)
+
+// Cluster is one registered cluster, its controller runs until the cluster is removed.
+type Cluster struct {
+	// Name is the registered name of the cluster.
+	Name string
+	// Client is the clients of the cluster.
+	Client *client.ManagerClient
+	// Controller is the controller of the cluster, Start it to inform the cluster.
+	Controller ggp.ControllerService
+	// stopCh is closed when the cluster is removed.
+	stopCh chan struct{}
+}
+
+// Object is an object found in one cluster by the fan-out queries.
+type Object struct {
+	// Cluster is the name of the cluster the object was found in.
+	Cluster string
+	// Object is the cached object, it is shared with the cache and must not be modified.
+	Object interface{}
+}
+
+// FanOutError is returned by the fan-out queries when some clusters failed,
+// the objects of the other clusters are still returned.
+type FanOutError struct {
+	// Errors is the error of every failed cluster.
+	Errors map[string]error
+}
+
+func (e *FanOutError) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	msgs := make([]string, 0, len(names))
+	for _, name := range names {
+		msgs = append(msgs, fmt.Sprintf("cluster %s: %v", name, e.Errors[name]))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Registry keeps the clients and the controller of every cluster, keyed by cluster name.
+type Registry struct {
+	// opts is the controller options of every cluster.
+	opts []workload.Option
+	// mu guards clusters.
+	mu       sync.RWMutex
+	clusters map[string]*Cluster
+}
+
+// NewRegistry return an empty registry, opts configure the controller of every added cluster.
+func NewRegistry(opts ...workload.Option) *Registry {
+	return &Registry{
+		opts:     opts,
+		clusters: map[string]*Cluster{},
+	}
+}
+
+// Add register the cluster of the kubeconfig content, opts are added to the options of the registry.
+// The controller of the cluster is not started, see Start.
+func (r *Registry) Add(name string, kubeConfig []byte, opts ...workload.Option) (*Cluster, error) {
+	managerClient, err := client.NewManagerConfigClient(kubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("cluster %s: %v", name, err)
+	}
+	return r.AddClient(name, managerClient, opts...)
+}
+
+// AddClient register the cluster of managerClient, opts are added to the options of the registry.
+// The controller of the cluster is not started, see Start.
+func (r *Registry) AddClient(name string, managerClient *client.ManagerClient, opts ...workload.Option) (*Cluster, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.clusters[name]; ok {
+		return nil, fmt.Errorf("cluster %s: %w", name, ErrClusterExists)
+	}
+	stopCh := make(chan struct{})
+	c := &Cluster{
+		Name:       name,
+		Client:     managerClient,
+		Controller: workload.NewManagerController(managerClient, stopCh, append(append([]workload.Option{}, r.opts...), opts...)...),
+		stopCh:     stopCh,
+	}
+	r.clusters[name] = c
+	return c, nil
+}
+
+// Remove stop the controller of the cluster and unregister it.
+func (r *Registry) Remove(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.clusters[name]
+	if !ok {
+		return fmt.Errorf("cluster %s: %w", name, ErrClusterNotFound)
+	}
+	close(c.stopCh)
+	delete(r.clusters, name)
+	return nil
+}
+
+// Close stop the controllers of all clusters and unregister them.
+func (r *Registry) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, c := range r.clusters {
+		close(c.stopCh)
+		delete(r.clusters, name)
+	}
+}
+
+// Get return the registered cluster.
+func (r *Registry) Get(name string) (*Cluster, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.clusters[name]
+	if !ok {
+		return nil, fmt.Errorf("cluster %s: %w", name, ErrClusterNotFound)
+	}
+	return c, nil
+}
+
+// Names return the names of the registered clusters, sorted.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ret := make([]string, 0, len(r.clusters))
+	for name := range r.clusters {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// snapshot return the registered clusters sorted by name.
+func (r *Registry) snapshot() []*Cluster {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ret := make([]*Cluster, 0, len(r.clusters))
+	for _, c := range r.clusters {
+		ret = append(ret, c)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret
+}
+
+// Start start the controllers of all clusters in parallel and block until they have synced or ctx is done.
+// The clusters failing to sync are reported by a *FanOutError, the others keep running.
+func (r *Registry) Start(ctx context.Context) error {
+	clusters := r.snapshot()
+	errs := make([]error, len(clusters))
+	var wg sync.WaitGroup
+	for i, c := range clusters {
+		wg.Add(1)
+		go func(i int, c *Cluster) {
+			defer wg.Done()
+			errs[i] = c.Controller.Start(ctx)
+		}(i, c)
+	}
+	wg.Wait()
+	fanOutErr := &FanOutError{Errors: map[string]error{}}
+	for i, c := range clusters {
+		if errs[i] != nil {
+			fanOutErr.Errors[c.Name] = errs[i]
+		}
+	}
+	if len(fanOutErr.Errors) == 0 {
+		return nil
+	}
+	return fanOutErr
+}
+
+// FanOut run fn against the controller of every cluster and collect the objects it returns.
+// The clusters returning ErrNotFound are skipped, the other errors are reported by a *FanOutError
+// along with the objects of the other clusters.
+func (r *Registry) FanOut(fn func(controller ggp.ControllerService) ([]interface{}, error)) ([]Object, error) {
+	ret := make([]Object, 0)
+	fanOutErr := &FanOutError{Errors: map[string]error{}}
+	for _, c := range r.snapshot() {
+		objs, err := fn(c.Controller)
+		if err != nil {
+			if !errors.Is(err, workload.ErrNotFound) {
+				fanOutErr.Errors[c.Name] = err
+			}
+			continue
+		}
+		for _, obj := range objs {
+			ret = append(ret, Object{Cluster: c.Name, Object: obj})
+		}
+	}
+	if len(fanOutErr.Errors) == 0 {
+		return ret, nil
+	}
+	return ret, fanOutErr
+}
+
+// List return the objects of the kind, such as Pod, under this namespace matching the selector in every cluster.
+func (r *Registry) List(kind, namespace string, selector ggp.Selector) ([]Object, error) {
+	return r.FanOut(func(controller ggp.ControllerService) ([]interface{}, error) {
+		return controller.List(kind, namespace, selector)
+	})
+}
+
+// FindPod return the pod with the namespace and name in every cluster having it.
+// ErrNotFound is returned when no cluster has it and no cluster failed.
+func (r *Registry) FindPod(namespace, name string) ([]Object, error) {
+	ret, err := r.FanOut(func(controller ggp.ControllerService) ([]interface{}, error) {
+		pod, err := controller.GetPod(namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{pod}, nil
+	})
+	if err == nil && len(ret) == 0 {
+		return nil, &workload.QueryError{Kind: workload.Pod.String(), Namespace: namespace, Name: name, Err: workload.ErrNotFound}
+	}
+	return ret, err
+}
+
+// FindPodByLabel return the pods under this namespace whose labels contain every pair of labels in every cluster.
+func (r *Registry) FindPodByLabel(namespace string, labels map[string]string) ([]Object, error) {
+	return r.FanOut(func(controller ggp.ControllerService) ([]interface{}, error) {
+		pods, err := controller.GetPodByLabel(namespace, labels)
+		if err != nil {
+			return nil, err
+		}
+		return podObjects(pods), nil
+	})
+}
+
+// podObjects return the pods as objects.
+func podObjects(pods []*corev1.Pod) []interface{} {
+	ret := make([]interface{}, 0, len(pods))
+	for _, pod := range pods {
+		ret = append(ret, pod)
+	}
+	return ret
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"reflect"
+	"testing"
+	"x6t.io/ggp"
+	"x6t.io/ggp/workload"
+)
+
+const mockKubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: edge
+  cluster:
+    server: https://127.0.0.1:1
+users:
+- name: admin
+  user:
+    token: token
+contexts:
+- name: edge
+  context:
+    cluster: edge
+    user: admin
+current-context: edge
+`
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry(workload.WithResources(workload.Pod))
+	defer r.Close()
+	for _, name := range []string{"edge-b", "edge-a"} {
+		if _, err := r.Add(name, []byte(mockKubeConfig)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := r.Add("edge-a", []byte(mockKubeConfig)); !errors.Is(err, ErrClusterExists) {
+		t.Errorf("Add() error = %v, want ErrClusterExists", err)
+	}
+	if got, want := r.Names(), []string{"edge-a", "edge-b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+
+	// the controllers are not started, every cluster reports its own error.
+	_, err := r.FindPod("default", "mqtt-0")
+	fanOutErr := &FanOutError{}
+	if !errors.As(err, &fanOutErr) || len(fanOutErr.Errors) != 2 || !errors.Is(fanOutErr.Errors["edge-a"], workload.ErrNotSynced) {
+		t.Errorf("FindPod() error = %v, want ErrNotSynced of both clusters", err)
+	}
+
+	if err := r.Remove("edge-a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Get("edge-a"); !errors.Is(err, ErrClusterNotFound) {
+		t.Errorf("Get() error = %v, want ErrClusterNotFound", err)
+	}
+	if err := r.Remove("edge-a"); !errors.Is(err, ErrClusterNotFound) {
+		t.Errorf("Remove() error = %v, want ErrClusterNotFound", err)
+	}
+	if got, want := r.Names(), []string{"edge-b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+}
+
+// newMockPod return the pod under edge labeled with app.
+func newMockPod(name, app string) *corev1.Pod {
+	return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "edge", Name: name, Labels: map[string]string{"app": app}}}
+}
+
+// addFakeCluster register the cluster of a fake clientset seeded with objects.
+func addFakeCluster(t *testing.T, r *Registry, name string, objects ...runtime.Object) {
+	t.Helper()
+	stopCh := make(chan struct{})
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clusters[name] = &Cluster{
+		Name:       name,
+		Controller: workload.NewController(fake.NewSimpleClientset(objects...), stopCh, r.opts...),
+		stopCh:     stopCh,
+	}
+}
+
+// clustersOf return the cluster and name of every found pod.
+func clustersOf(objs []Object) []string {
+	ret := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		ret = append(ret, obj.Cluster+"/"+obj.Object.(*corev1.Pod).Name)
+	}
+	return ret
+}
+
+func TestRegistryFanOut(t *testing.T) {
+	r := NewRegistry(workload.WithResources(workload.Pod))
+	defer r.Close()
+	addFakeCluster(t, r, "edge-a", newMockPod("mqtt-0", "mqtt"), newMockPod("broker-0", "broker"))
+	addFakeCluster(t, r, "edge-b", newMockPod("mqtt-0", "mqtt"), newMockPod("mqtt-1", "mqtt"))
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	objs, err := r.FindPod("edge", "mqtt-0")
+	if got, want := clustersOf(objs), []string{"edge-a/mqtt-0", "edge-b/mqtt-0"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("FindPod(mqtt-0) = %v, %v, want %v", got, err, want)
+	}
+	objs, err = r.FindPod("edge", "broker-0")
+	if got, want := clustersOf(objs), []string{"edge-a/broker-0"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("FindPod(broker-0) = %v, %v, want %v", got, err, want)
+	}
+	if _, err := r.FindPod("edge", "mqtt-2"); !errors.Is(err, workload.ErrNotFound) {
+		t.Errorf("FindPod(mqtt-2) error = %v, want ErrNotFound", err)
+	}
+
+	objs, err = r.FindPodByLabel("edge", map[string]string{"app": "mqtt"})
+	if got, want := clustersOf(objs), []string{"edge-a/mqtt-0", "edge-b/mqtt-0", "edge-b/mqtt-1"}; err != nil || !sameNames(got, want) {
+		t.Errorf("FindPodByLabel(app=mqtt) = %v, %v, want %v", got, err, want)
+	}
+
+	objs, err = r.FanOut(func(controller ggp.ControllerService) ([]interface{}, error) {
+		pods, err := controller.ListPods("edge", ggp.SelectorFromSet(map[string]string{"app": "broker"}))
+		if err != nil {
+			return nil, err
+		}
+		return podObjects(pods), nil
+	})
+	if got, want := clustersOf(objs), []string{"edge-a/broker-0"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("FanOut(ListPods app=broker) = %v, %v, want %v", got, err, want)
+	}
+
+	// the error of one cluster is reported beside the objects of the other.
+	objs, err = r.FanOut(func(controller ggp.ControllerService) ([]interface{}, error) {
+		if _, err := controller.GetPod("edge", "mqtt-1"); err != nil {
+			return nil, errors.New("failed")
+		}
+		return []interface{}{newMockPod("mqtt-1", "mqtt")}, nil
+	})
+	fanOutErr := &FanOutError{}
+	if got, want := clustersOf(objs), []string{"edge-b/mqtt-1"}; !reflect.DeepEqual(got, want) ||
+		!errors.As(err, &fanOutErr) || len(fanOutErr.Errors) != 1 || fanOutErr.Errors["edge-a"] == nil {
+		t.Errorf("FanOut() = %v, %v, want %v and the error of edge-a", got, err, want)
+	}
+}
+
+// sameNames return true if got and want hold the same names in any order.
+func sameNames(got, want []string) bool {
+	count := map[string]int{}
+	for _, name := range got {
+		count[name]++
+	}
+	for _, name := range want {
+		count[name]--
+	}
+	for _, n := range count {
+		if n != 0 {
+			return false
+		}
+	}
+	return len(got) == len(want)
+}
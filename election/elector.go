@@ -0,0 +1,248 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package election elects one leader among the replicas of a service with a coordination.k8s.io Lease,
+// so that the informers run on every replica while the reconcilers, and any write, run only on the leader.
+package election
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"os"
+	"sync"
+	"time"
+	"x6t.io/ggp/client"
+)
+
+// ErrRunning is returned by Run while the elector is running.
+var ErrRunning = errors.New("elector running")
+
+// Elector campaigns for the lease of its name until the context of Run is done.
+// While it holds the lease it runs its runnables, they are cancelled and waited for once the lease is lost,
+// then it campaigns again. The runnables must return within the lease duration, such as a reconcile manager
+// whose drain timeout is shorter, or a new leader may run beside them.
+type Elector struct {
+	client  *client.ManagerClient
+	name    string
+	options *options
+	// mu guards leader, leading and running.
+	mu      sync.RWMutex
+	leader  string
+	leading bool
+	running bool
+}
+
+// NewElector return the elector of the lease name among the replicas sharing it, the lease is created
+// and renewed with the KubeClient of client, reloaded clients are used from the next campaign.
+func NewElector(client *client.ManagerClient, name string, opts ...Option) (*Elector, error) {
+	if name == "" {
+		return nil, errors.New("lease name is empty")
+	}
+	o := &options{
+		namespace:     client.Namespace(),
+		leaseDuration: DefaultLeaseDuration,
+		renewDeadline: DefaultRenewDeadline,
+		retryPeriod:   DefaultRetryPeriod,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.namespace == "" {
+		o.namespace = metav1.NamespaceDefault
+	}
+	if o.identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("identity of the lease %s: %v", name, err)
+		}
+		o.identity = hostname + "_" + rand.String(8)
+	}
+	e := &Elector{client: client, name: name, options: o}
+	// the config is checked once here rather than on every campaign.
+	if _, err := e.newLeaderElector(&term{}, func() {}); err != nil {
+		return nil, fmt.Errorf("lease %s: %v", name, err)
+	}
+	return e, nil
+}
+
+// Identity return the identity of this replica in the lease.
+func (e *Elector) Identity() string {
+	return e.options.identity
+}
+
+// IsLeader return true while this replica leads and runs the runnables.
+func (e *Elector) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.leading
+}
+
+// Leader return the identity of the last leader observed, empty until one is observed.
+func (e *Elector) Leader() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.leader
+}
+
+// Run campaign for the lease until ctx is done, the lease is released if this replica leads.
+// It returns once the runnables returned, it can be called again once it returned.
+func (e *Elector) Run(ctx context.Context) error {
+	e.mu.Lock()
+	if e.running {
+		e.mu.Unlock()
+		return ErrRunning
+	}
+	e.running = true
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		e.running = false
+		e.mu.Unlock()
+	}()
+
+	for {
+		// a failing runnable ends the term, another replica may then lead.
+		termCtx, cancel := context.WithCancel(ctx)
+		t := &term{done: make(chan struct{})}
+		le, err := e.newLeaderElector(t, cancel)
+		if err != nil {
+			cancel()
+			return err
+		}
+		le.Run(termCtx)
+		cancel()
+		if t.end() {
+			e.mu.Lock()
+			e.leading = false
+			e.mu.Unlock()
+			if e.options.callbacks.OnStoppedLeading != nil {
+				e.options.callbacks.OnStoppedLeading()
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(e.options.retryPeriod):
+		}
+	}
+}
+
+// term is one campaign, leading once the lease is acquired.
+type term struct {
+	// mu guards started and ended.
+	mu      sync.Mutex
+	started bool
+	ended   bool
+	// done is closed once the runnables of the term returned.
+	done chan struct{}
+}
+
+// start return false if the campaign already ended, the leadership came too late.
+func (t *term) start() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ended {
+		return false
+	}
+	t.started = true
+	return true
+}
+
+// end the campaign, wait for the runnables and return true if it was leading.
+func (t *term) end() bool {
+	t.mu.Lock()
+	t.ended = true
+	started := t.started
+	t.mu.Unlock()
+	if started {
+		<-t.done
+	}
+	return started
+}
+
+// newLeaderElector return the elector of the campaign t, endTerm is called when a runnable fails.
+func (e *Elector) newLeaderElector(t *term, endTerm func()) (*leaderelection.LeaderElector, error) {
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Namespace: e.options.namespace, Name: e.name},
+		Client:     e.client.KubeClient().CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: e.options.identity},
+	}
+	return leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		Name:            e.name,
+		LeaseDuration:   e.options.leaseDuration,
+		RenewDeadline:   e.options.renewDeadline,
+		RetryPeriod:     e.options.retryPeriod,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			// OnStartedLeading runs in its own goroutine while the lease is renewed.
+			OnStartedLeading: func(ctx context.Context) {
+				if !t.start() {
+					return
+				}
+				defer close(t.done)
+				e.lead(ctx, endTerm)
+			},
+			// the end of the term is handled by Run, as it must wait for the runnables.
+			OnStoppedLeading: func() {},
+			OnNewLeader: func(identity string) {
+				e.mu.Lock()
+				e.leader = identity
+				e.mu.Unlock()
+				if e.options.callbacks.OnNewLeader != nil {
+					e.options.callbacks.OnNewLeader(identity)
+				}
+			},
+		},
+	})
+}
+
+// lead run the runnables until ctx is done, once the caches of the controller have synced.
+func (e *Elector) lead(ctx context.Context, endTerm func()) {
+	e.mu.Lock()
+	e.leading = true
+	e.mu.Unlock()
+	if e.options.callbacks.OnStartedLeading != nil {
+		go e.options.callbacks.OnStartedLeading(ctx)
+	}
+	if e.options.controller != nil {
+		if err := e.options.controller.Start(ctx); err != nil {
+			if ctx.Err() == nil {
+				utilruntime.HandleError(fmt.Errorf("lease %s: wait for the caches: %v", e.name, err))
+				endTerm()
+			}
+			return
+		}
+	}
+	var wg sync.WaitGroup
+	for _, r := range e.options.runnables {
+		wg.Add(1)
+		go func(r Runnable) {
+			defer wg.Done()
+			if err := r.Start(ctx); err != nil {
+				utilruntime.HandleError(fmt.Errorf("lease %s: %v", e.name, err))
+				endTerm()
+			}
+		}(r)
+	}
+	wg.Wait()
+}
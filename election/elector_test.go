@@ -0,0 +1,236 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package election_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"x6t.io/ggp/election"
+	"x6t.io/ggp/ggptest"
+	"x6t.io/ggp/reconcile"
+	"x6t.io/ggp/workload"
+)
+
+// fastLease renews the lease quickly for the tests.
+var fastLease = election.WithLeaseDuration(time.Second, 500*time.Millisecond, 100*time.Millisecond)
+
+// runnable records its runs, failing the first fail runs.
+type runnable struct {
+	started, stopped int32
+	fail             int32
+}
+
+func (r *runnable) Start(ctx context.Context) error {
+	n := atomic.AddInt32(&r.started, 1)
+	if n <= atomic.LoadInt32(&r.fail) {
+		return errors.New("failed")
+	}
+	<-ctx.Done()
+	atomic.AddInt32(&r.stopped, 1)
+	return nil
+}
+
+// run the elector until the returned func is called, it waits for Run to return.
+func run(t *testing.T, e *election.Elector) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- e.Run(ctx) }()
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			cancel()
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Errorf("Run() error = %v", err)
+				}
+			case <-time.After(ggptest.DefaultTimeout):
+				t.Errorf("Run() did not return once stopped")
+			}
+		})
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+// eventually retry check until it succeeds, the test fails if it does not in time.
+func eventually(t *testing.T, check func() error) {
+	t.Helper()
+	deadline := time.Now().Add(ggptest.DefaultTimeout)
+	for {
+		err := check()
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func leading(e *election.Elector) func() error {
+	return func() error {
+		if !e.IsLeader() {
+			return fmt.Errorf("%s does not lead, leader %q", e.Identity(), e.Leader())
+		}
+		return nil
+	}
+}
+
+func TestElector(t *testing.T) {
+	fake, err := ggptest.NewFake("edge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stoppedA int32
+	var startedA int32
+	ra, rb := &runnable{}, &runnable{}
+	a, err := election.NewElector(fake.Client, "ggp", election.WithIdentity("a"), fastLease, election.WithRunnable(ra),
+		election.WithCallbacks(election.Callbacks{
+			OnStartedLeading: func(ctx context.Context) { atomic.AddInt32(&startedA, 1) },
+			OnStoppedLeading: func() { atomic.AddInt32(&stoppedA, 1) },
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := election.NewElector(fake.Client, "ggp", election.WithIdentity("b"), fastLease, election.WithRunnable(rb))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stopA := run(t, a)
+	eventually(t, leading(a))
+	if err := a.Run(context.Background()); !errors.Is(err, election.ErrRunning) {
+		t.Errorf("Run() twice error = %v, want ErrRunning", err)
+	}
+	run(t, b)
+	eventually(t, func() error {
+		if got := b.Leader(); got != "a" {
+			return fmt.Errorf("b observed leader %q, want a", got)
+		}
+		return nil
+	})
+	time.Sleep(300 * time.Millisecond)
+	if b.IsLeader() || atomic.LoadInt32(&rb.started) != 0 {
+		t.Errorf("b leads while a holds the lease")
+	}
+	if runs, callbacks := atomic.LoadInt32(&ra.started), atomic.LoadInt32(&startedA); runs != 1 || callbacks != 1 {
+		t.Errorf("a started %d runs and %d callbacks, want 1", runs, callbacks)
+	}
+
+	// a releases the lease on shutdown, b takes over.
+	stopA()
+	if a.IsLeader() || atomic.LoadInt32(&ra.stopped) != 1 || atomic.LoadInt32(&stoppedA) != 1 {
+		t.Errorf("a still leads once Run returned")
+	}
+	eventually(t, leading(b))
+	eventually(t, func() error {
+		if atomic.LoadInt32(&rb.started) != 1 {
+			return errors.New("b leads without running its runnable")
+		}
+		return nil
+	})
+	lease, err := fake.Kube.CoordinationV1().Leases("edge").Get(context.Background(), "ggp", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != "b" {
+		t.Errorf("lease holder = %v, want b", lease.Spec.HolderIdentity)
+	}
+}
+
+func TestElectorRunnableFailed(t *testing.T) {
+	fake, err := ggptest.NewFake("edge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &runnable{fail: 1}
+	e, err := election.NewElector(fake.Client, "ggp", fastLease, election.WithRunnable(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	run(t, e)
+	// the failed run ends the term, the elector leads again.
+	eventually(t, func() error {
+		if got := atomic.LoadInt32(&r.started); got != 2 {
+			return fmt.Errorf("%d runs, want 2", got)
+		}
+		return nil
+	})
+	eventually(t, leading(e))
+}
+
+func TestElectorReconcile(t *testing.T) {
+	deploy := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "edge", Name: "mqtt"}}
+	h := ggptest.NewHarness(t, []runtime.Object{deploy}, workload.WithResources(workload.Deployment))
+	var reconciled int32
+	m := reconcile.NewManager(h.Controller, reconcile.WithDrainTimeout(200*time.Millisecond))
+	if err := m.Register("Deployment", reconcile.ReconcilerFunc(func(ctx context.Context, key string) (time.Duration, error) {
+		atomic.AddInt32(&reconciled, 1)
+		return 0, nil
+	})); err != nil {
+		t.Fatal(err)
+	}
+	e, err := election.NewElector(h.Client, "ggp", fastLease, election.WithController(h.Controller), election.WithRunnable(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := run(t, e)
+	eventually(t, func() error {
+		if atomic.LoadInt32(&reconciled) != 1 {
+			return errors.New("the leader did not reconcile")
+		}
+		return nil
+	})
+	stop()
+	// the reconcilers are stopped on the replica which is not leading, the informers are not.
+	h.Create(&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "edge", Name: "broker"}})
+	h.AssertCached("Deployment", "edge", "broker")
+	time.Sleep(100 * time.Millisecond)
+	if got := atomic.LoadInt32(&reconciled); got != 1 {
+		t.Errorf("%d reconciles, want none once the leadership is lost", got)
+	}
+}
+
+func TestNewElector(t *testing.T) {
+	fake, err := ggptest.NewFake("edge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := election.NewElector(fake.Client, ""); err == nil {
+		t.Errorf("NewElector() without name succeeded")
+	}
+	if _, err := election.NewElector(fake.Client, "ggp", election.WithLeaseDuration(time.Second, 2*time.Second, time.Second)); err == nil {
+		t.Errorf("NewElector() with a renew deadline longer than the lease succeeded")
+	}
+	e, err := election.NewElector(fake.Client, "ggp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Identity() == "" {
+		t.Errorf("Identity() is empty")
+	}
+}
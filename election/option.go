@@ -0,0 +1,114 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package election
+
+import (
+	"context"
+	"time"
+	"x6t.io/ggp"
+)
+
+const (
+	// DefaultLeaseDuration is the default time the other replicas wait before taking over a lease not renewed.
+	DefaultLeaseDuration = time.Second * 15
+	// DefaultRenewDeadline is the default time the leader retries renewing its lease before giving up the leadership.
+	DefaultRenewDeadline = time.Second * 10
+	// DefaultRetryPeriod is the default time between two attempts to acquire or renew the lease.
+	DefaultRetryPeriod = time.Second * 2
+)
+
+// Callbacks is notified of the changes of the leadership, every callback is optional.
+type Callbacks struct {
+	// OnStartedLeading is called once this replica leads, ctx is done when the leadership is lost.
+	// It runs in its own goroutine.
+	OnStartedLeading func(ctx context.Context)
+	// OnStoppedLeading is called once this replica lost the leadership and its runnables returned.
+	OnStoppedLeading func()
+	// OnNewLeader is called with the identity of the leader when another replica, or this one, is elected.
+	OnNewLeader func(identity string)
+}
+
+// Runnable is run only while this replica leads, such as a *reconcile.Manager.
+// Start must block until ctx is done, it is called again on every leadership.
+type Runnable interface {
+	Start(ctx context.Context) error
+}
+
+// Option configures the elector given to NewElector.
+type Option func(*options)
+
+type options struct {
+	// namespace of the lease, the namespace of the ManagerClient by default.
+	namespace string
+	// identity of this replica in the lease, the hostname and a random suffix by default.
+	identity      string
+	leaseDuration time.Duration
+	renewDeadline time.Duration
+	retryPeriod   time.Duration
+	callbacks     Callbacks
+	// controller is waited for before starting the runnables.
+	controller ggp.ControllerService
+	runnables  []Runnable
+}
+
+// WithNamespace set the namespace of the lease, the namespace of the ManagerClient by default.
+func WithNamespace(namespace string) Option {
+	return func(o *options) {
+		o.namespace = namespace
+	}
+}
+
+// WithIdentity set the identity of this replica in the lease, it must be unique among the replicas.
+// Default the hostname, which is the pod name in k8s, and a random suffix.
+func WithIdentity(identity string) Option {
+	return func(o *options) {
+		o.identity = identity
+	}
+}
+
+// WithLeaseDuration set how long the other replicas wait before taking over a lease not renewed,
+// how long the leader retries renewing it and the time between two attempts.
+// Default DefaultLeaseDuration, DefaultRenewDeadline and DefaultRetryPeriod.
+func WithLeaseDuration(leaseDuration, renewDeadline, retryPeriod time.Duration) Option {
+	return func(o *options) {
+		o.leaseDuration = leaseDuration
+		o.renewDeadline = renewDeadline
+		o.retryPeriod = retryPeriod
+	}
+}
+
+// WithCallbacks set the callbacks notified of the changes of the leadership.
+func WithCallbacks(callbacks Callbacks) Option {
+	return func(o *options) {
+		o.callbacks = callbacks
+	}
+}
+
+// WithController wait for the caches of controller to sync before starting the runnables.
+// The controller informs on every replica, only its Start is called by the elector to wait for it.
+func WithController(controller ggp.ControllerService) Option {
+	return func(o *options) {
+		o.controller = controller
+	}
+}
+
+// WithRunnable run the runnables only while this replica leads, such as the reconcile managers.
+func WithRunnable(runnables ...Runnable) Option {
+	return func(o *options) {
+		o.runnables = append(o.runnables, runnables...)
+	}
+}
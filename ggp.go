@@ -17,23 +17,220 @@ limitations under the License.
 package ggp
 
 import (
+	"context"
+	networking "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	istiolisters "istio.io/client-go/pkg/listers/networking/v1alpha3"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev2 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	autoscalinglisters "k8s.io/client-go/listers/autoscaling/v2"
 	corev1 "k8s.io/client-go/listers/core/v1"
+	networkinglisters "k8s.io/client-go/listers/networking/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/client-go/tools/cache"
+	"time"
 )
 
+// ControllerService caches the k8s resources with informers and queries them.
+// The objects returned by the listers and the queries are shared with the caches and must not be modified.
+// The query errors tell not found, not synced, disabled kinds and unwatched namespaces apart with errors.Is,
+// see the errors of the workload package.
+// The listers are never nil. The listers of the kinds not informed, or informed as metadata only, are empty:
+// their Get returns ErrKindDisabled or ErrMetadataOnly and their List returns nothing, the queries tell why.
 type ControllerService interface {
 	// Ready k8s Informer ready status.
 	Ready() bool
-	// Start start k8s Informer.
-	Start() error
+	// Start start k8s Informer, block until they have synced or ctx is done.
+	Start(ctx context.Context) error
+	// Stop stop k8s Informer and clear the caches, Start can run them again.
+	Stop()
+	// Restart stop k8s Informer and list again against clientset, nil keeps the current client.
+	Restart(clientset kubernetes.Interface) error
+	// SyncStatus return the sync status of every enabled informer.
+	SyncStatus() []SyncStatus
 	// PodLister is k8s pod lister.
 	PodLister() corev1.PodLister
 	// GetPod return get the specified pod resource based on the namespace and pod name.
-	GetPod(namespace, name string) *corev2.Pod
+	GetPod(namespace, name string) (*corev2.Pod, error)
 	// GetPodByNameSpace return get all pods under this namespace.
 	GetPodByNameSpace(namespace string) ([]*corev2.Pod, error)
-	// GetPodByLabel return get exact matching pods based on namespace and label.
+	// GetPodByLabel return get the pods under this namespace whose labels contain every pair of labels.
 	GetPodByLabel(namespace string, labels map[string]string) ([]*corev2.Pod, error)
-	// GetPodEventMessage return used to save events, only the latest one is saved. make sure it's unique.
+	// GetPodBySelector return get the pods under this namespace matching the label and field selector.
+	GetPodBySelector(namespace string, selector Selector) ([]*corev2.Pod, error)
+	// GetPodByNode return get all pods scheduled to the node.
+	GetPodByNode(nodeName string) ([]*corev2.Pod, error)
+	// GetPodByOwner return get all pods owned by the object with the uid, such as a ReplicaSet.
+	GetPodByOwner(uid string) ([]*corev2.Pod, error)
+	// List return the objects of the kind, such as Pod, under this namespace matching the selector.
+	// Ingress and HorizontalPodAutoscaler are returned as networking.k8s.io/v1 and autoscaling/v2.
+	List(kind, namespace string, selector Selector) ([]interface{}, error)
+	// NamespaceLister is k8s Namespace lister.
+	NamespaceLister() corev1.NamespaceLister
+	// GetNamespace return get the specified Namespace based on the name.
+	GetNamespace(name string) (*corev2.Namespace, error)
+	// ListNamespaces return get the Namespaces matching the selector.
+	ListNamespaces(selector Selector) ([]*corev2.Namespace, error)
+	// NodeLister is k8s Node lister.
+	NodeLister() corev1.NodeLister
+	// GetNode return get the specified Node based on the name.
+	GetNode(name string) (*corev2.Node, error)
+	// ListNodes return get the Nodes matching the selector.
+	ListNodes(selector Selector) ([]*corev2.Node, error)
+	// StorageClassLister is k8s StorageClass lister.
+	StorageClassLister() storagelisters.StorageClassLister
+	// GetStorageClass return get the specified StorageClass based on the name.
+	GetStorageClass(name string) (*storagev1.StorageClass, error)
+	// ListStorageClasses return get the StorageClasses matching the selector.
+	ListStorageClasses(selector Selector) ([]*storagev1.StorageClass, error)
+	// ListPods return get the Pods under this namespace matching the selector, all namespaces if empty.
+	ListPods(namespace string, selector Selector) ([]*corev2.Pod, error)
+	// ServiceLister is k8s Service lister.
+	ServiceLister() corev1.ServiceLister
+	// GetService return get the specified Service based on the namespace and name.
+	GetService(namespace, name string) (*corev2.Service, error)
+	// ListServices return get the Services under this namespace matching the selector, all namespaces if empty.
+	ListServices(namespace string, selector Selector) ([]*corev2.Service, error)
+	// EndpointsLister is k8s Endpoints lister.
+	EndpointsLister() corev1.EndpointsLister
+	// GetEndpoints return get the specified Endpoints based on the namespace and name.
+	GetEndpoints(namespace, name string) (*corev2.Endpoints, error)
+	// ListEndpoints return get the Endpoints under this namespace matching the selector, all namespaces if empty.
+	ListEndpoints(namespace string, selector Selector) ([]*corev2.Endpoints, error)
+	// SecretLister is k8s Secret lister.
+	SecretLister() corev1.SecretLister
+	// GetSecret return get the specified Secret based on the namespace and name.
+	GetSecret(namespace, name string) (*corev2.Secret, error)
+	// ListSecrets return get the Secrets under this namespace matching the selector, all namespaces if empty.
+	ListSecrets(namespace string, selector Selector) ([]*corev2.Secret, error)
+	// ConfigMapLister is k8s ConfigMap lister.
+	ConfigMapLister() corev1.ConfigMapLister
+	// GetConfigMap return get the specified ConfigMap based on the namespace and name.
+	GetConfigMap(namespace, name string) (*corev2.ConfigMap, error)
+	// ListConfigMaps return get the ConfigMaps under this namespace matching the selector, all namespaces if empty.
+	ListConfigMaps(namespace string, selector Selector) ([]*corev2.ConfigMap, error)
+	// PersistentVolumeClaimLister is k8s PersistentVolumeClaim lister.
+	PersistentVolumeClaimLister() corev1.PersistentVolumeClaimLister
+	// GetPersistentVolumeClaim return get the specified PersistentVolumeClaim based on the namespace and name.
+	GetPersistentVolumeClaim(namespace, name string) (*corev2.PersistentVolumeClaim, error)
+	// ListPersistentVolumeClaims return get the PersistentVolumeClaims under this namespace matching the selector, all namespaces if empty.
+	ListPersistentVolumeClaims(namespace string, selector Selector) ([]*corev2.PersistentVolumeClaim, error)
+	// EventLister is k8s Event lister.
+	EventLister() corev1.EventLister
+	// GetEvent return get the specified Event based on the namespace and name.
+	GetEvent(namespace, name string) (*corev2.Event, error)
+	// ListEvents return get the Events under this namespace matching the selector, all namespaces if empty.
+	ListEvents(namespace string, selector Selector) ([]*corev2.Event, error)
+	// DeploymentLister is k8s Deployment lister.
+	DeploymentLister() appslisters.DeploymentLister
+	// GetDeployment return get the specified Deployment based on the namespace and name.
+	GetDeployment(namespace, name string) (*appsv1.Deployment, error)
+	// ListDeployments return get the Deployments under this namespace matching the selector, all namespaces if empty.
+	ListDeployments(namespace string, selector Selector) ([]*appsv1.Deployment, error)
+	// StatefulSetLister is k8s StatefulSet lister.
+	StatefulSetLister() appslisters.StatefulSetLister
+	// GetStatefulSet return get the specified StatefulSet based on the namespace and name.
+	GetStatefulSet(namespace, name string) (*appsv1.StatefulSet, error)
+	// ListStatefulSets return get the StatefulSets under this namespace matching the selector, all namespaces if empty.
+	ListStatefulSets(namespace string, selector Selector) ([]*appsv1.StatefulSet, error)
+	// ReplicaSetLister is k8s ReplicaSet lister.
+	ReplicaSetLister() appslisters.ReplicaSetLister
+	// GetReplicaSet return get the specified ReplicaSet based on the namespace and name.
+	GetReplicaSet(namespace, name string) (*appsv1.ReplicaSet, error)
+	// ListReplicaSets return get the ReplicaSets under this namespace matching the selector, all namespaces if empty.
+	ListReplicaSets(namespace string, selector Selector) ([]*appsv1.ReplicaSet, error)
+	// IngressLister is k8s networking.k8s.io/v1 Ingress lister, the Ingresses of an apiserver serving
+	// extensions/v1beta1 only are converted once when they are cached.
+	IngressLister() networkinglisters.IngressLister
+	// GetIngress return get the specified Ingress based on the namespace and name,
+	// as networking.k8s.io/v1 whichever version the apiserver serves.
+	GetIngress(namespace, name string) (*networkingv1.Ingress, error)
+	// ListIngresses return get the Ingresses under this namespace matching the selector, all namespaces if empty.
+	ListIngresses(namespace string, selector Selector) ([]*networkingv1.Ingress, error)
+	// HorizontalPodAutoscalerLister is k8s autoscaling/v2 HorizontalPodAutoscaler lister, the HorizontalPodAutoscalers
+	// of an apiserver serving autoscaling/v2beta2 only are converted once when they are cached.
+	HorizontalPodAutoscalerLister() autoscalinglisters.HorizontalPodAutoscalerLister
+	// GetHorizontalPodAutoscaler return get the specified HorizontalPodAutoscaler based on the namespace and name,
+	// as autoscaling/v2 whichever version the apiserver serves.
+	GetHorizontalPodAutoscaler(namespace, name string) (*autoscalingv2.HorizontalPodAutoscaler, error)
+	// ListHorizontalPodAutoscalers return get the HorizontalPodAutoscalers under this namespace matching the selector, all namespaces if empty.
+	ListHorizontalPodAutoscalers(namespace string, selector Selector) ([]*autoscalingv2.HorizontalPodAutoscaler, error)
+	// GatewayLister is k8s Gateway lister.
+	GatewayLister() istiolisters.GatewayLister
+	// GetGateway return get the specified Gateway based on the namespace and name.
+	GetGateway(namespace, name string) (*networking.Gateway, error)
+	// ListGateways return get the Gateways under this namespace matching the selector, all namespaces if empty.
+	ListGateways(namespace string, selector Selector) ([]*networking.Gateway, error)
+	// VirtualServiceLister is k8s VirtualService lister.
+	VirtualServiceLister() istiolisters.VirtualServiceLister
+	// GetVirtualService return get the specified VirtualService based on the namespace and name.
+	GetVirtualService(namespace, name string) (*networking.VirtualService, error)
+	// ListVirtualServices return get the VirtualServices under this namespace matching the selector, all namespaces if empty.
+	ListVirtualServices(namespace string, selector Selector) ([]*networking.VirtualService, error)
+	// DestinationRuleLister is k8s DestinationRule lister.
+	DestinationRuleLister() istiolisters.DestinationRuleLister
+	// GetDestinationRule return get the specified DestinationRule based on the namespace and name.
+	GetDestinationRule(namespace, name string) (*networking.DestinationRule, error)
+	// ListDestinationRules return get the DestinationRules under this namespace matching the selector, all namespaces if empty.
+	ListDestinationRules(namespace string, selector Selector) ([]*networking.DestinationRule, error)
+	// RegisterDynamic inform the resource through the dynamic client, such as a CRD, namespaced or cluster scoped.
+	// The informer starts at once if the controller is running, and is reported by Ready and SyncStatus.
+	RegisterDynamic(gvr schema.GroupVersionResource, namespaced bool) error
+	// DynamicLister return the lister of the registered resource, nil if it is not registered.
+	DynamicLister(gvr schema.GroupVersionResource) cache.GenericLister
+	// GetDynamic return get the specified object of the registered resource based on the namespace and name.
+	GetDynamic(gvr schema.GroupVersionResource, namespace, name string) (*unstructured.Unstructured, error)
+	// ListDynamic return get the objects of the registered resource under this namespace matching the selector, all namespaces if empty.
+	ListDynamic(gvr schema.GroupVersionResource, namespace string, selector Selector) ([]*unstructured.Unstructured, error)
+	// Subscribe return the changes of the kind under this namespace matching the selector, all namespaces if empty.
+	// The kind is a k8s kind such as Pod, or a resource registered by RegisterDynamic such as devices.devices.kubeedge.io.
+	// The objects already cached are sent as Added first, and again after Restart as the caches are listed again.
+	Subscribe(kind, namespace string, selector Selector, opts ...SubscribeOption) (Subscription, error)
+	// GetEvents return the events of the object grouped by reason and sorted by last timestamp.
+	GetEvents(namespace, kind, name string) ([]EventGroup, error)
+	// LatestWarning return the latest Warning event of the object.
+	LatestWarning(namespace, kind, name string) (*corev2.Event, error)
+	// GetPodEventMessage return the message of the latest event of the object.
+	// Deprecated: use GetEvents or LatestWarning.
 	GetPodEventMessage(namespace, kind, name string) string
 }
+
+// SyncStatus is the sync status of one informer.
+type SyncStatus struct {
+	// Name is the informer name, such as Pod or Claims, the resource and group of the dynamic informers.
+	Name string
+	// Synced is true once the informer has listed all objects.
+	Synced bool
+	// ResourceVersion is the resource version of the last list or watch event.
+	ResourceVersion string
+	// GroupVersion is the group version the informer lists, such as networking.k8s.io/v1.
+	GroupVersion string
+	// MetadataOnly is true if the informer only caches the metadata of the objects.
+	MetadataOnly bool
+	// Err is why the informer could not be built, such as an istio kind without istio client, it never syncs.
+	Err error
+}
+
+// EventGroup is the events of one object sharing the same reason.
+type EventGroup struct {
+	// Reason is the reason of the events, such as BackOff.
+	Reason string
+	// Type is the type of the latest event, Normal or Warning.
+	Type string
+	// Message is the message of the latest event.
+	Message string
+	// Count is how many times the events occurred.
+	Count int32
+	// FirstTimestamp is the first time the events occurred.
+	FirstTimestamp time.Time
+	// LastTimestamp is the last time the events occurred.
+	LastTimestamp time.Time
+	// Events is the events sorted by last timestamp, the oldest first.
+	Events []*corev2.Event
+}
@@ -0,0 +1,207 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ggptest runs the controllers of ggp against fake clients, without a k8s cluster.
+// The fakes are seeded from objects or YAML fixtures, changed by scripted creates, updates and deletes,
+// and the caches of the controller are checked with the assertion helpers of Harness.
+package ggptest
+
+import (
+	"fmt"
+	istiofake "istio.io/client-go/pkg/clientset/versioned/fake"
+	istioscheme "istio.io/client-go/pkg/clientset/versioned/scheme"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	metadatafake "k8s.io/client-go/metadata/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"strings"
+	"x6t.io/ggp/client"
+	"x6t.io/ggp/workload"
+)
+
+// Scheme is the scheme of the k8s and istio kinds the fakes and the fixtures know.
+var Scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(scheme.AddToScheme(Scheme))
+	utilruntime.Must(istioscheme.AddToScheme(Scheme))
+}
+
+// Fake is the fake clients behind a ManagerClient, each object is kept by the k8s or the istio clientset,
+// by the dynamic client and by the metadata client, so every informer of the controller sees it.
+type Fake struct {
+	// Client is the ManagerClient of the fakes, see client.NewManagerClientFromInterfaces.
+	Client   *client.ManagerClient
+	Kube     *kubefake.Clientset
+	Istio    *istiofake.Clientset
+	Dynamic  *dynamicfake.FakeDynamicClient
+	Metadata *metadatafake.FakeMetadataClient
+	// metadata is the tracker of Metadata, the fake metadata client does not expose its own.
+	metadata k8stesting.ObjectTracker
+}
+
+// NewFake return the fake clients seeded with objects, whose default namespace is namespace.
+// The resources of the unstructured objects, such as CRDs, can be listed through the dynamic client.
+func NewFake(namespace string, objects ...runtime.Object) (*Fake, error) {
+	return NewFakeWithListKinds(namespace, nil, objects...)
+}
+
+// NewFakeWithListKinds is NewFake whose dynamic client lists the resources of listKinds as well,
+// such as {devices.kubeedge.io/v1alpha2 devices: DeviceList} for a CRD without seeded objects.
+func NewFakeWithListKinds(namespace string, listKinds map[schema.GroupVersionResource]string, objects ...runtime.Object) (*Fake, error) {
+	kinds := map[schema.GroupVersionResource]string{}
+	for gvr, kind := range listKinds {
+		kinds[gvr] = kind
+	}
+	for _, obj := range objects {
+		if u, ok := obj.(*unstructured.Unstructured); ok && !Scheme.Recognizes(u.GroupVersionKind()) {
+			kinds[resourceOf(u.GroupVersionKind())] = u.GetKind() + "List"
+		}
+	}
+	metadataScheme := runtime.NewScheme()
+	if err := metav1.AddMetaToScheme(metadataScheme); err != nil {
+		return nil, err
+	}
+	f := &Fake{
+		Kube:     kubefake.NewSimpleClientset(),
+		Istio:    istiofake.NewSimpleClientset(),
+		Dynamic:  newDynamicClient(kinds),
+		Metadata: metadatafake.NewSimpleMetadataClient(metadataScheme),
+	}
+	f.metadata = k8stesting.NewObjectTracker(metadataScheme, serializer.NewCodecFactory(metadataScheme).UniversalDeserializer())
+	f.Metadata.PrependReactor("*", "*", k8stesting.ObjectReaction(f.metadata))
+	f.Metadata.PrependWatchReactor("*", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		w, err := f.metadata.Watch(action.GetResource(), action.GetNamespace())
+		return err == nil, w, err
+	})
+	f.Client = client.NewManagerClientFromInterfaces(f.Kube, f.Dynamic, f.Istio, f.Metadata, namespace)
+	for _, obj := range objects {
+		if err := f.Create(obj); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Create add obj to the fakes, the watching informers receive an added event.
+func (f *Fake) Create(obj runtime.Object) error {
+	return f.apply(obj, func(tracker k8stesting.ObjectTracker, gvr schema.GroupVersionResource, obj runtime.Object, namespace string) error {
+		return tracker.Create(gvr, obj, namespace)
+	})
+}
+
+// Update replace obj in the fakes, the watching informers receive a modified event.
+func (f *Fake) Update(obj runtime.Object) error {
+	return f.apply(obj, func(tracker k8stesting.ObjectTracker, gvr schema.GroupVersionResource, obj runtime.Object, namespace string) error {
+		return tracker.Update(gvr, obj, namespace)
+	})
+}
+
+// Delete remove obj from the fakes, the watching informers receive a deleted event.
+func (f *Fake) Delete(obj runtime.Object) error {
+	return f.apply(obj, func(tracker k8stesting.ObjectTracker, gvr schema.GroupVersionResource, obj runtime.Object, namespace string) error {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return err
+		}
+		return tracker.Delete(gvr, namespace, accessor.GetName())
+	})
+}
+
+// apply run op on the typed, the dynamic and the metadata trackers of obj.
+func (f *Fake) apply(obj runtime.Object, op func(k8stesting.ObjectTracker, schema.GroupVersionResource, runtime.Object, string) error) error {
+	gvk, err := kindOf(obj)
+	if err != nil {
+		return err
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+	typed := obj.DeepCopyObject()
+	typed.GetObjectKind().SetGroupVersionKind(gvk)
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(typed)
+	if err != nil {
+		return err
+	}
+	partial := meta.AsPartialObjectMetadata(accessor).DeepCopy()
+	partial.SetGroupVersionKind(gvk)
+
+	gvr, namespace := resourceOf(gvk), accessor.GetNamespace()
+	// the kinds unknown to Scheme, such as CRDs, are only kept by the dynamic and the metadata clients.
+	if Scheme.Recognizes(gvk) {
+		tracker := f.Kube.Tracker()
+		if istioscheme.Scheme.Recognizes(gvk) {
+			tracker = f.Istio.Tracker()
+		}
+		if err := op(tracker, gvr, typed, namespace); err != nil {
+			return err
+		}
+	}
+	if err := op(f.Dynamic.Tracker(), gvr, &unstructured.Unstructured{Object: content}, namespace); err != nil {
+		return err
+	}
+	return op(f.metadata, gvr, partial, namespace)
+}
+
+// newDynamicClient return the fake dynamic client of the kinds of Scheme and of listKinds, as unstructured objects.
+func newDynamicClient(listKinds map[schema.GroupVersionResource]string) *dynamicfake.FakeDynamicClient {
+	unstructuredScheme := runtime.NewScheme()
+	for gvk := range Scheme.AllKnownTypes() {
+		if strings.HasSuffix(gvk.Kind, "List") {
+			unstructuredScheme.AddKnownTypeWithName(gvk, &unstructured.UnstructuredList{})
+			continue
+		}
+		unstructuredScheme.AddKnownTypeWithName(gvk, &unstructured.Unstructured{})
+		if Scheme.Recognizes(gvk.GroupVersion().WithKind(gvk.Kind + "List")) {
+			listKinds[resourceOf(gvk)] = gvk.Kind + "List"
+		}
+	}
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(unstructuredScheme, listKinds)
+}
+
+// kindOf return the group version kind of obj, from its type if it has no type meta.
+func kindOf(obj runtime.Object) (schema.GroupVersionKind, error) {
+	if gvk := obj.GetObjectKind().GroupVersionKind(); !gvk.Empty() {
+		return gvk, nil
+	}
+	gvks, _, err := Scheme.ObjectKinds(obj)
+	if err != nil {
+		return schema.GroupVersionKind{}, err
+	}
+	if len(gvks) == 0 {
+		return schema.GroupVersionKind{}, fmt.Errorf("no kind of %T", obj)
+	}
+	return gvks[0], nil
+}
+
+// resourceOf return the resource of gvk, the fake trackers guess wrong some of them, such as gateways.
+func resourceOf(gvk schema.GroupVersionKind) schema.GroupVersionResource {
+	if kind, ok := workload.ParseKind(gvk.Kind); ok {
+		return gvk.GroupVersion().WithResource(kind.Resource())
+	}
+	gvr, _ := meta.UnsafeGuessKindToResource(gvk)
+	return gvr
+}
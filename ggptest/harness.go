@@ -0,0 +1,219 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ggptest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+	"x6t.io/ggp"
+	"x6t.io/ggp/workload"
+)
+
+const (
+	// DefaultTimeout is the longest time the assertions wait for the controller to see the changes.
+	DefaultTimeout = time.Second * 5
+	// pollPeriod is how often the assertions check the caches.
+	pollPeriod = time.Millisecond * 10
+)
+
+// Harness is a started controller of the fake clients, stopped when the test ends.
+type Harness struct {
+	*Fake
+	// Controller is the controller of NewManagerController on the fake ManagerClient.
+	Controller ggp.ControllerService
+	// Timeout is the longest time the assertions wait, default DefaultTimeout.
+	Timeout time.Duration
+	t       testing.TB
+}
+
+// NewHarness start the controller of the fake clients seeded with objects and wait until it is synced,
+// the test fails if it does not. The controller is stopped by the cleanup of t.
+func NewHarness(t testing.TB, objects []runtime.Object, opts ...workload.Option) *Harness {
+	t.Helper()
+	f, err := NewFake("default", objects...)
+	if err != nil {
+		t.Fatalf("seed the fake clients: %v", err)
+	}
+	stopCh := make(chan struct{})
+	t.Cleanup(func() { close(stopCh) })
+	c := workload.NewManagerController(f.Client, stopCh, opts...)
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+	if err := c.Start(ctx); err != nil {
+		t.Fatalf("start the controller: %v", err)
+	}
+	return &Harness{Fake: f, Controller: c, Timeout: DefaultTimeout, t: t}
+}
+
+// NewFixtureHarness is NewHarness seeded with the objects of the YAML fixture files.
+func NewFixtureHarness(t testing.TB, paths []string, opts ...workload.Option) *Harness {
+	t.Helper()
+	objects, err := LoadFixtures(paths...)
+	if err != nil {
+		t.Fatalf("load the fixtures: %v", err)
+	}
+	return NewHarness(t, objects, opts...)
+}
+
+// Create add the objects in order, the test fails on the first error.
+func (h *Harness) Create(objects ...runtime.Object) {
+	h.t.Helper()
+	for _, obj := range objects {
+		if err := h.Fake.Create(obj); err != nil {
+			h.t.Fatalf("create %s: %v", describe(obj), err)
+		}
+	}
+}
+
+// Update replace the objects in order, the test fails on the first error.
+func (h *Harness) Update(objects ...runtime.Object) {
+	h.t.Helper()
+	for _, obj := range objects {
+		if err := h.Fake.Update(obj); err != nil {
+			h.t.Fatalf("update %s: %v", describe(obj), err)
+		}
+	}
+}
+
+// Delete remove the objects in order, the test fails on the first error.
+func (h *Harness) Delete(objects ...runtime.Object) {
+	h.t.Helper()
+	for _, obj := range objects {
+		if err := h.Fake.Delete(obj); err != nil {
+			h.t.Fatalf("delete %s: %v", describe(obj), err)
+		}
+	}
+}
+
+// Eventually wait until check returns nil, the test fails with its last error after Timeout.
+// The watch events reach the controller asynchronously, so the caches are checked until they settle.
+func (h *Harness) Eventually(check func() error) {
+	h.t.Helper()
+	var last error
+	if err := wait.PollImmediate(pollPeriod, h.Timeout, func() (bool, error) {
+		last = check()
+		return last == nil, nil
+	}); err != nil {
+		h.t.Fatal(last)
+	}
+}
+
+// AssertCached wait until the object of the kind is cached, such as Pod, and return it.
+func (h *Harness) AssertCached(kind, namespace, name string) interface{} {
+	h.t.Helper()
+	var ret interface{}
+	h.Eventually(func() error {
+		obj, err := h.cached(kind, namespace, name)
+		ret = obj
+		return err
+	})
+	return ret
+}
+
+// AssertNotCached wait until the object of the kind is not cached.
+func (h *Harness) AssertNotCached(kind, namespace, name string) {
+	h.t.Helper()
+	h.Eventually(func() error {
+		_, err := h.cached(kind, namespace, name)
+		if err == nil {
+			return fmt.Errorf("%s %s/%s is cached, want it removed", kind, namespace, name)
+		}
+		if !errors.Is(err, workload.ErrNotFound) {
+			return err
+		}
+		return nil
+	})
+}
+
+// AssertNames wait until the names of the cached objects of the kind under namespace are want,
+// of every namespace if namespace is empty.
+func (h *Harness) AssertNames(kind, namespace string, want ...string) {
+	h.t.Helper()
+	sort.Strings(want)
+	h.Eventually(func() error {
+		got, err := h.names(kind, namespace)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(got, want) && !(len(got) == 0 && len(want) == 0) {
+			return fmt.Errorf("%s names under %q = %v, want %v", kind, namespace, got, want)
+		}
+		return nil
+	})
+}
+
+// AssertCachedWith wait until the object of the kind is cached and check accepts it, such as its labels.
+func (h *Harness) AssertCachedWith(kind, namespace, name string, check func(obj interface{}) error) {
+	h.t.Helper()
+	h.Eventually(func() error {
+		obj, err := h.cached(kind, namespace, name)
+		if err != nil {
+			return err
+		}
+		return check(obj)
+	})
+}
+
+// cached return the cached object of the kind, a QueryError with ErrNotFound if it is not cached.
+func (h *Harness) cached(kind, namespace, name string) (interface{}, error) {
+	items, err := h.Controller.List(kind, namespace, ggp.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if accessor, err := meta.Accessor(item); err == nil && accessor.GetName() == name {
+			return item, nil
+		}
+	}
+	return nil, &workload.QueryError{Kind: kind, Namespace: namespace, Name: name, Err: workload.ErrNotFound}
+}
+
+// names return the sorted names of the cached objects of the kind.
+func (h *Harness) names(kind, namespace string) ([]string, error) {
+	items, err := h.Controller.List(kind, namespace, ggp.Everything())
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(items))
+	for _, item := range items {
+		accessor, err := meta.Accessor(item)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, accessor.GetName())
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// describe return the kind, namespace and name of obj for the failures.
+func describe(obj runtime.Object) string {
+	gvk, _ := kindOf(obj)
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return gvk.Kind
+	}
+	return fmt.Sprintf("%s %s/%s", gvk.Kind, accessor.GetNamespace(), accessor.GetName())
+}
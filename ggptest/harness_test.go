@@ -0,0 +1,106 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ggptest
+
+import (
+	"context"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"testing"
+	"x6t.io/ggp/workload"
+)
+
+func TestParseFixtures(t *testing.T) {
+	objects, err := LoadFixtures("testdata/edge.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		kinds = append(kinds, obj.GetObjectKind().GroupVersionKind().Kind)
+	}
+	if got, want := fmt.Sprint(kinds), "[Namespace Pod Service Gateway]"; got != want {
+		t.Errorf("LoadFixtures() kinds = %s, want %s", got, want)
+	}
+	if _, err := ParseFixtures([]byte("apiVersion: v1\nkind: Unknown\n")); err == nil {
+		t.Errorf("ParseFixtures() of an unknown kind error = nil")
+	}
+}
+
+func TestFake(t *testing.T) {
+	objects, err := LoadFixtures("testdata/edge.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewFake("edge", objects...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := f.Kube.CoreV1().Pods("edge").Get(ctx, "mqtt-0", metav1.GetOptions{}); err != nil {
+		t.Errorf("Kube Get() error = %v", err)
+	}
+	if _, err := f.Istio.NetworkingV1alpha3().Gateways("edge").Get(ctx, "mqtt", metav1.GetOptions{}); err != nil {
+		t.Errorf("Istio Get() error = %v", err)
+	}
+	gateways := schema.GroupVersionResource{Group: "networking.istio.io", Version: "v1alpha3", Resource: "gateways"}
+	if list, err := f.Dynamic.Resource(gateways).Namespace("edge").List(ctx, metav1.ListOptions{}); err != nil || len(list.Items) != 1 {
+		t.Errorf("Dynamic List() = %v, %v, want 1 Gateway", list, err)
+	}
+	pods := corev1.SchemeGroupVersion.WithResource("pods")
+	if got, err := f.Metadata.Resource(pods).Namespace("edge").Get(ctx, "mqtt-0", metav1.GetOptions{}); err != nil || got.Labels["app"] != "mqtt" {
+		t.Errorf("Metadata Get() = %v, %v, want the labels of mqtt-0", got, err)
+	}
+	if got := f.Client.Namespace(); got != "edge" {
+		t.Errorf("Client.Namespace() = %q, want edge", got)
+	}
+}
+
+func TestHarness(t *testing.T) {
+	h := NewFixtureHarness(t, []string{"testdata/edge.yaml"}, workload.WithMetadataOnly(workload.ConfigMap))
+	h.AssertNames("Pod", "edge", "mqtt-0")
+	h.AssertCached("Gateway", "edge", "mqtt")
+
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "edge", Name: "mqtt-1", Labels: map[string]string{"app": "mqtt"}}}
+	h.Create(pod)
+	h.AssertNames("Pod", "edge", "mqtt-0", "mqtt-1")
+
+	updated := pod.DeepCopy()
+	updated.Labels["version"] = "v2"
+	h.Update(updated)
+	h.AssertCachedWith("Pod", "edge", "mqtt-1", func(obj interface{}) error {
+		if got := obj.(*corev1.Pod).Labels["version"]; got != "v2" {
+			return fmt.Errorf("mqtt-1 version = %q, want v2", got)
+		}
+		return nil
+	})
+
+	h.Delete(updated)
+	h.AssertNotCached("Pod", "edge", "mqtt-1")
+	h.AssertNames("Pod", "edge", "mqtt-0")
+
+	// the metadata only kinds are fed by the fake metadata client.
+	h.Create(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "edge", Name: "mqtt"}, Data: map[string]string{"mqtt.conf": "listener 1883"}})
+	h.AssertCachedWith("ConfigMap", "edge", "mqtt", func(obj interface{}) error {
+		if data := obj.(*corev1.ConfigMap).Data; data != nil {
+			return fmt.Errorf("mqtt data = %v, want the metadata only", data)
+		}
+		return nil
+	})
+}
@@ -3,9 +3,11 @@ module x6t.io/ggp
 go 1.16
 
 require (
+	github.com/spf13/pflag v1.0.5
 	istio.io/client-go v1.12.1
 	k8s.io/api v0.23.1
 	k8s.io/apimachinery v0.23.1
 	k8s.io/client-go v0.23.1
 	sigs.k8s.io/controller-runtime v0.11.1
+	sigs.k8s.io/yaml v1.3.0
 )
@@ -0,0 +1,337 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package reconcile runs reconcilers on the changes cached by a ggp.ControllerService,
+// through rate limited work queues with retries, owner based mapping and a graceful drain on shutdown.
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"sync"
+	"time"
+	"x6t.io/ggp"
+)
+
+var (
+	// ErrRunning is returned by Start and Register while the manager is running.
+	ErrRunning = errors.New("reconcile manager running")
+	// ErrRegistered is returned by Register when the kind already has a reconciler.
+	ErrRegistered = errors.New("kind already registered")
+)
+
+// Reconciler bring the object of key to its desired state, the key is "namespace/name", or "name" for cluster
+// scoped kinds, see SplitKey. The object may be gone from the caches when it is deleted.
+// Returning an error retries the key with an exponential backoff, a positive requeueAfter reconciles it again
+// after the delay. A key is never reconciled by two workers at once.
+type Reconciler interface {
+	Reconcile(ctx context.Context, key string) (requeueAfter time.Duration, err error)
+}
+
+// ReconcilerFunc is a function implementing Reconciler.
+type ReconcilerFunc func(ctx context.Context, key string) (time.Duration, error)
+
+// Reconcile call f.
+func (f ReconcilerFunc) Reconcile(ctx context.Context, key string) (time.Duration, error) {
+	return f(ctx, key)
+}
+
+// MapFunc return the keys of the reconciled kind to enqueue for the change of a watched kind, see WithWatch.
+type MapFunc func(change ggp.Change) []string
+
+// Key return the key of obj, "namespace/name" or "name" for cluster scoped objects.
+func Key(obj interface{}) (string, error) {
+	return cache.MetaNamespaceKeyFunc(obj)
+}
+
+// SplitKey return the namespace and the name of key.
+func SplitKey(key string) (namespace, name string, err error) {
+	return cache.SplitMetaNamespaceKey(key)
+}
+
+// Manager runs the registered reconcilers, Start runs them until its context is done.
+type Manager struct {
+	controller ggp.ControllerService
+	options    *options
+	// mu guards registrations and running.
+	mu            sync.Mutex
+	registrations []*registration
+	running       bool
+}
+
+// registration is one reconciler and the kinds enqueuing its keys.
+type registration struct {
+	kind       string
+	reconciler Reconciler
+	options    *options
+	// queue is the keys to reconcile, created by each Start while holding the manager mu.
+	queue workqueue.RateLimitingInterface
+}
+
+// NewManager return the manager of the reconcilers of the kinds cached by controller,
+// opts are the defaults of every reconciler.
+func NewManager(controller ggp.ControllerService, opts ...Option) *Manager {
+	return &Manager{
+		controller: controller,
+		options:    newOptions(opts...),
+	}
+}
+
+// Register reconcile the objects of kind, such as Deployment, with reconciler, each change of an object enqueues its key.
+// The kinds must be informed by the controller, they are subscribed by Start.
+func (m *Manager) Register(kind string, reconciler Reconciler, opts ...Option) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.running {
+		return ErrRunning
+	}
+	for _, r := range m.registrations {
+		if r.kind == kind {
+			return fmt.Errorf("register %s: %w", kind, ErrRegistered)
+		}
+	}
+	m.registrations = append(m.registrations, &registration{
+		kind:       kind,
+		reconciler: reconciler,
+		options:    m.options.with(opts...),
+	})
+	return nil
+}
+
+// Start run the registered reconcilers until ctx is done, then stop watching the changes,
+// enqueue the keys of the changes already received, wait for the queued keys up to the drain timeout and return. It can be called again once it returned,
+// such as after regaining the leadership, the objects are then reconciled again.
+func (m *Manager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	if m.running {
+		m.mu.Unlock()
+		return ErrRunning
+	}
+	m.running = true
+	registrations := append([]*registration{}, m.registrations...)
+	for _, r := range registrations {
+		o := r.options
+		r.queue = workqueue.NewNamedRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(o.backoffBase, o.backoffMax), r.kind)
+	}
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.running = false
+		m.mu.Unlock()
+	}()
+
+	var subscriptions []ggp.Subscription
+	unsubscribe := func() {
+		for _, s := range subscriptions {
+			s.Unsubscribe()
+		}
+	}
+	var forwarders sync.WaitGroup
+	for _, r := range registrations {
+		o := r.options
+		s, err := m.controller.Subscribe(r.kind, o.namespace, o.selector, ggp.WithBuffer(ggp.DefaultSubscribeBufferSize, ggp.BlockPolicy))
+		if err != nil {
+			unsubscribe()
+			return fmt.Errorf("watch %s: %v", r.kind, err)
+		}
+		subscriptions = append(subscriptions, s)
+		forwarders.Add(1)
+		go m.forward(&forwarders, r, s, keyOf)
+
+		for _, w := range o.watches {
+			s, err := m.controller.Subscribe(w.kind, o.namespace, ggp.Everything(), ggp.WithBuffer(ggp.DefaultSubscribeBufferSize, ggp.BlockPolicy))
+			if err != nil {
+				unsubscribe()
+				return fmt.Errorf("watch %s for %s: %v", w.kind, r.kind, err)
+			}
+			subscriptions = append(subscriptions, s)
+			mapFunc := w.mapFunc
+			if mapFunc == nil {
+				mapFunc = m.EnqueueOwner(r.kind)
+			}
+			forwarders.Add(1)
+			go m.forward(&forwarders, r, s, mapFunc)
+		}
+	}
+
+	// the running reconciles keep their context while the queues drain.
+	workCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var workers sync.WaitGroup
+	for _, r := range registrations {
+		for i := 0; i < r.options.workers; i++ {
+			workers.Add(1)
+			go func(r *registration) {
+				defer workers.Done()
+				for m.processNext(workCtx, r) {
+				}
+			}(r)
+		}
+	}
+
+	<-ctx.Done()
+	// the pending changes are forwarded to the queues before they drain.
+	for _, s := range subscriptions {
+		s.Close()
+	}
+	forwarders.Wait()
+	for _, r := range registrations {
+		go r.queue.ShutDownWithDrain()
+	}
+	drained := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(m.options.drainTimeout):
+		utilruntime.HandleError(fmt.Errorf("reconcile manager: queues not drained after %s, cancel the running reconciles", m.options.drainTimeout))
+		cancel()
+		for _, r := range registrations {
+			r.queue.ShutDown()
+		}
+		<-drained
+	}
+	return nil
+}
+
+// Len return the number of keys waiting in the queues of the running reconcilers,
+// not counting those being reconciled.
+func (m *Manager) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.running {
+		return 0
+	}
+	n := 0
+	for _, r := range m.registrations {
+		if r.queue != nil {
+			n += r.queue.Len()
+		}
+	}
+	return n
+}
+
+// keyOf is the MapFunc of the reconciled kind, the key of the changed object.
+func keyOf(change ggp.Change) []string {
+	key, err := Key(change.Object())
+	if err != nil {
+		utilruntime.HandleError(err)
+		return nil
+	}
+	return []string{key}
+}
+
+// forward enqueue the keys of the changes of s until it is closed.
+func (m *Manager) forward(wg *sync.WaitGroup, r *registration, s ggp.Subscription, mapFunc MapFunc) {
+	defer wg.Done()
+	for change := range s.Changes() {
+		for _, key := range mapFunc(change) {
+			r.queue.Add(key)
+		}
+	}
+}
+
+// processNext reconcile the next key of the queue, false once the queue is shut down.
+func (m *Manager) processNext(ctx context.Context, r *registration) bool {
+	item, shutdown := r.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer r.queue.Done(item)
+	key := item.(string)
+	requeueAfter, err := m.reconcile(ctx, r, key)
+	switch {
+	case err != nil:
+		utilruntime.HandleError(fmt.Errorf("reconcile %s %s: %v", r.kind, key, err))
+		r.queue.AddRateLimited(key)
+	case requeueAfter > 0:
+		r.queue.Forget(key)
+		r.queue.AddAfter(key, requeueAfter)
+	default:
+		r.queue.Forget(key)
+	}
+	return true
+}
+
+// reconcile call the reconciler, a panic is retried as an error so a worker is never lost.
+func (m *Manager) reconcile(ctx context.Context, r *registration, key string) (requeueAfter time.Duration, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return r.reconciler.Reconcile(ctx, key)
+}
+
+// maxOwnerDepth is the longest chain of owners followed by EnqueueOwner, such as Pod, ReplicaSet, Deployment.
+const maxOwnerDepth = 8
+
+// EnqueueOwner return the MapFunc enqueuing the owner of ownerKind of the changed object, following the
+// controller owner references through the caches, such as the Deployment of the ReplicaSet owning a Pod.
+// Nothing is enqueued if an owner in the chain is not cached.
+func (m *Manager) EnqueueOwner(ownerKind string) MapFunc {
+	return func(change ggp.Change) []string {
+		obj := change.Object()
+		for depth := 0; depth < maxOwnerDepth; depth++ {
+			o, err := meta.Accessor(obj)
+			if err != nil {
+				return nil
+			}
+			ref := metav1.GetControllerOf(o)
+			if ref == nil {
+				return nil
+			}
+			if ref.Kind == ownerKind {
+				return []string{namespacedKey(o.GetNamespace(), ref.Name)}
+			}
+			if obj, err = m.get(ref.Kind, o.GetNamespace(), ref.Name, string(ref.UID)); err != nil || obj == nil {
+				return nil
+			}
+		}
+		return nil
+	}
+}
+
+// get return the cached object of kind under namespace with this name and uid, nil if it is not cached.
+func (m *Manager) get(kind, namespace, name, uid string) (interface{}, error) {
+	items, err := m.controller.List(kind, namespace, ggp.Selector{Field: fields.OneTermEqualSelector("metadata.name", name)})
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if o, err := meta.Accessor(item); err == nil && string(o.GetUID()) == uid {
+			return item, nil
+		}
+	}
+	return nil, nil
+}
+
+// namespacedKey return the key of the object under namespace with this name.
+func namespacedKey(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
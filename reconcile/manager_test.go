@@ -0,0 +1,347 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconcile_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"x6t.io/ggp"
+	"x6t.io/ggp/ggptest"
+	"x6t.io/ggp/reconcile"
+	"x6t.io/ggp/workload"
+)
+
+// recorder is a Reconciler recording the reconciled keys.
+type recorder struct {
+	mu   sync.Mutex
+	keys []string
+}
+
+func (r *recorder) Reconcile(ctx context.Context, key string) (time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys = append(r.keys, key)
+	return 0, nil
+}
+
+// has return an error unless every key of want was reconciled.
+func (r *recorder) has(want ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	seen := map[string]bool{}
+	for _, key := range r.keys {
+		seen[key] = true
+	}
+	for _, key := range want {
+		if !seen[key] {
+			return fmt.Errorf("reconciled %v, want %s", r.keys, key)
+		}
+	}
+	return nil
+}
+
+// start run m until the test ends, the returned func stops it and waits for Start to return.
+func start(t *testing.T, m *reconcile.Manager) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- m.Start(ctx) }()
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			cancel()
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Errorf("Start() error = %v", err)
+				}
+			case <-time.After(ggptest.DefaultTimeout):
+				t.Errorf("Start() did not return once stopped")
+			}
+		})
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+func newDeployment(name string) *appsv1.Deployment {
+	return &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "edge", Name: name, UID: types.UID(name)}}
+}
+
+// newOwned return an object of kind owned by the controller owner.
+func newOwned(owner metav1.Object, kind string, meta metav1.ObjectMeta) metav1.ObjectMeta {
+	meta.Namespace = owner.GetNamespace()
+	meta.UID = types.UID(meta.Name)
+	isController := true
+	meta.OwnerReferences = []metav1.OwnerReference{{Kind: kind, Name: owner.GetName(), UID: owner.GetUID(), Controller: &isController}}
+	return meta
+}
+
+func TestManager(t *testing.T) {
+	deploy := newDeployment("mqtt")
+	rs := &appsv1.ReplicaSet{ObjectMeta: newOwned(deploy, "Deployment", metav1.ObjectMeta{Name: "mqtt-7d4b9"})}
+	h := ggptest.NewHarness(t, []runtime.Object{deploy, rs}, workload.WithResources(workload.Deployment, workload.ReplicaSet, workload.Pod))
+
+	m := reconcile.NewManager(h.Controller)
+	deployments := &recorder{}
+	if err := m.Register("Deployment", deployments, reconcile.WithOwned("Pod"), reconcile.WithOwned("ReplicaSet")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Register("Deployment", deployments); !errors.Is(err, reconcile.ErrRegistered) {
+		t.Errorf("Register() twice error = %v, want ErrRegistered", err)
+	}
+	stop := start(t, m)
+	h.Eventually(func() error { return deployments.has("edge/mqtt") })
+
+	deployments.mu.Lock()
+	deployments.keys = nil
+	deployments.mu.Unlock()
+	// a Pod enqueues the Deployment owning its ReplicaSet.
+	h.Create(&corev1.Pod{ObjectMeta: newOwned(rs, "ReplicaSet", metav1.ObjectMeta{Name: "mqtt-7d4b9-x2x5z"})})
+	h.Eventually(func() error { return deployments.has("edge/mqtt") })
+	// a Pod without owner in the caches enqueues nothing.
+	h.Create(&corev1.Pod{ObjectMeta: newOwned(&appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Namespace: "edge", Name: "gone"}}, "ReplicaSet", metav1.ObjectMeta{Name: "gone-x"})})
+	h.Create(newDeployment("broker"))
+	h.Eventually(func() error { return deployments.has("edge/broker") })
+	deployments.mu.Lock()
+	for _, key := range deployments.keys {
+		if key != "edge/mqtt" && key != "edge/broker" {
+			t.Errorf("reconciled %s, want only the Deployments", key)
+		}
+	}
+	deployments.mu.Unlock()
+
+	stop()
+	if err := m.Register("ReplicaSet", &recorder{}); err != nil {
+		t.Errorf("Register() once stopped error = %v", err)
+	}
+	// the manager starts again, such as once the leadership is regained.
+	deployments.mu.Lock()
+	deployments.keys = nil
+	deployments.mu.Unlock()
+	start(t, m)
+	h.Eventually(func() error { return deployments.has("edge/mqtt", "edge/broker") })
+}
+
+func TestManagerWatch(t *testing.T) {
+	h := ggptest.NewHarness(t, nil, workload.WithResources(workload.Deployment, workload.ConfigMap))
+	m := reconcile.NewManager(h.Controller)
+	deployments := &recorder{}
+	// every Deployment uses the ConfigMap named like it.
+	byName := func(change ggp.Change) []string {
+		key, _ := reconcile.Key(change.Object())
+		return []string{key}
+	}
+	if err := m.Register("Deployment", deployments, reconcile.WithNamespace("edge"), reconcile.WithWatch("ConfigMap", byName)); err != nil {
+		t.Fatal(err)
+	}
+	start(t, m)
+	h.Create(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "edge", Name: "mqtt"}})
+	h.Eventually(func() error { return deployments.has("edge/mqtt") })
+}
+
+func TestManagerRetry(t *testing.T) {
+	h := ggptest.NewHarness(t, []runtime.Object{newDeployment("mqtt")}, workload.WithResources(workload.Deployment))
+	var attempts []time.Time
+	var mu sync.Mutex
+	failing := reconcile.ReconcilerFunc(func(ctx context.Context, key string) (time.Duration, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts = append(attempts, time.Now())
+		if len(attempts) < 4 {
+			return 0, errors.New("not yet")
+		}
+		return 0, nil
+	})
+	m := reconcile.NewManager(h.Controller, reconcile.WithBackoff(20*time.Millisecond, time.Second))
+	if err := m.Register("Deployment", failing); err != nil {
+		t.Fatal(err)
+	}
+	start(t, m)
+	h.Eventually(func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(attempts) < 4 {
+			return fmt.Errorf("%d attempts, want 4", len(attempts))
+		}
+		return nil
+	})
+	mu.Lock()
+	// the delays double from the base, 20ms, 40ms and 80ms.
+	for i := 1; i < 4; i++ {
+		want := 20 * time.Millisecond << uint(i-1)
+		if got := attempts[i].Sub(attempts[i-1]); got < want {
+			t.Errorf("retry %d after %s, want at least %s", i, got, want)
+		}
+	}
+	mu.Unlock()
+	time.Sleep(200 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(attempts) != 4 {
+		t.Errorf("%d attempts, want no retry after a success", len(attempts))
+	}
+}
+
+func TestManagerRequeueAfter(t *testing.T) {
+	h := ggptest.NewHarness(t, []runtime.Object{newDeployment("mqtt")}, workload.WithResources(workload.Deployment))
+	var count int32
+	periodic := reconcile.ReconcilerFunc(func(ctx context.Context, key string) (time.Duration, error) {
+		atomic.AddInt32(&count, 1)
+		return 10 * time.Millisecond, nil
+	})
+	m := reconcile.NewManager(h.Controller)
+	if err := m.Register("Deployment", periodic); err != nil {
+		t.Fatal(err)
+	}
+	start(t, m)
+	h.Eventually(func() error {
+		if got := atomic.LoadInt32(&count); got < 3 {
+			return fmt.Errorf("%d reconciles, want at least 3", got)
+		}
+		return nil
+	})
+}
+
+func TestManagerWorkers(t *testing.T) {
+	names := []string{"a", "b", "c", "d"}
+	objects := make([]runtime.Object, 0, len(names))
+	for _, name := range names {
+		objects = append(objects, newDeployment(name))
+	}
+	h := ggptest.NewHarness(t, objects, workload.WithResources(workload.Deployment))
+
+	var running, peak int32
+	release := make(chan struct{})
+	slow := reconcile.ReconcilerFunc(func(ctx context.Context, key string) (time.Duration, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		<-release
+		return 0, nil
+	})
+	m := reconcile.NewManager(h.Controller)
+	if err := m.Register("Deployment", slow, reconcile.WithWorkers(2)); err != nil {
+		t.Fatal(err)
+	}
+	start(t, m)
+	h.Eventually(func() error {
+		if got := atomic.LoadInt32(&running); got != 2 {
+			return fmt.Errorf("%d reconciles running, want 2", got)
+		}
+		return nil
+	})
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	if got := atomic.LoadInt32(&peak); got != 2 {
+		t.Errorf("%d reconciles ran at once, want 2 workers", got)
+	}
+}
+
+func TestManagerDrain(t *testing.T) {
+	names := []string{"a", "b", "c"}
+	objects := make([]runtime.Object, 0, len(names))
+	for _, name := range names {
+		objects = append(objects, newDeployment(name))
+	}
+	h := ggptest.NewHarness(t, objects, workload.WithResources(workload.Deployment))
+
+	started := make(chan struct{}, len(names))
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var done []string
+	blocking := reconcile.ReconcilerFunc(func(ctx context.Context, key string) (time.Duration, error) {
+		started <- struct{}{}
+		select {
+		case <-release:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		done = append(done, key)
+		return 0, nil
+	})
+	m := reconcile.NewManager(h.Controller)
+	if err := m.Register("Deployment", blocking); err != nil {
+		t.Fatal(err)
+	}
+	stop := start(t, m)
+	<-started
+	// the other keys wait in the queue while the first is reconciled.
+	h.Eventually(func() error {
+		if n := m.Len(); n != len(names)-1 {
+			return fmt.Errorf("Len() = %d, want %d", n, len(names)-1)
+		}
+		return nil
+	})
+	stopped := make(chan struct{})
+	go func() {
+		stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		t.Fatal("Start() returned before the queue is drained")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-stopped
+	mu.Lock()
+	defer mu.Unlock()
+	sort.Strings(done)
+	if fmt.Sprint(done) != "[edge/a edge/b edge/c]" {
+		t.Errorf("reconciled %v on shutdown, want every queued key", done)
+	}
+}
+
+func TestManagerDrainTimeout(t *testing.T) {
+	h := ggptest.NewHarness(t, []runtime.Object{newDeployment("mqtt")}, workload.WithResources(workload.Deployment))
+	started := make(chan struct{}, 1)
+	var cancelled int32
+	stuck := reconcile.ReconcilerFunc(func(ctx context.Context, key string) (time.Duration, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		atomic.StoreInt32(&cancelled, 1)
+		return 0, ctx.Err()
+	})
+	m := reconcile.NewManager(h.Controller, reconcile.WithDrainTimeout(50*time.Millisecond))
+	if err := m.Register("Deployment", stuck); err != nil {
+		t.Fatal(err)
+	}
+	stop := start(t, m)
+	<-started
+	stop()
+	if atomic.LoadInt32(&cancelled) != 1 {
+		t.Errorf("the running reconcile was not cancelled after the drain timeout")
+	}
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconcile
+
+import (
+	"time"
+	"x6t.io/ggp"
+)
+
+const (
+	// DefaultWorkers is the default number of keys of one reconciler reconciled at the same time.
+	DefaultWorkers = 1
+	// DefaultBackoffBase is the default delay before retrying a key after its first failure.
+	DefaultBackoffBase = time.Millisecond * 5
+	// DefaultBackoffMax is the default longest delay before retrying a failing key.
+	DefaultBackoffMax = time.Second * 1000
+	// DefaultDrainTimeout is the default longest time Start waits for the queued keys on shutdown.
+	DefaultDrainTimeout = time.Second * 30
+)
+
+// Option configures the manager given to NewManager, or one reconciler given to Register.
+// The options of NewManager are the defaults of every reconciler.
+type Option func(*options)
+
+type options struct {
+	// workers is the number of keys reconciled at the same time.
+	workers int
+	// backoffBase and backoffMax bound the exponential delay of the retries of a failing key.
+	backoffBase time.Duration
+	backoffMax  time.Duration
+	// drainTimeout is the longest time Start waits for the queued keys on shutdown, manager only.
+	drainTimeout time.Duration
+	// namespace and selector filter the objects of the reconciled kind.
+	namespace string
+	selector  ggp.Selector
+	// watches is the other kinds whose changes enqueue keys of the reconciled kind.
+	watches []watch
+}
+
+// watch is a kind whose changes are mapped to the keys of the reconciled kind.
+type watch struct {
+	kind string
+	// mapFunc is nil for WithOwned, the owners are looked up by the manager.
+	mapFunc MapFunc
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{
+		workers:      DefaultWorkers,
+		backoffBase:  DefaultBackoffBase,
+		backoffMax:   DefaultBackoffMax,
+		drainTimeout: DefaultDrainTimeout,
+		selector:     ggp.Everything(),
+	}
+	return o.with(opts...)
+}
+
+// with return a copy of o with opts applied.
+func (o *options) with(opts ...Option) *options {
+	ret := *o
+	ret.watches = append([]watch{}, o.watches...)
+	for _, opt := range opts {
+		opt(&ret)
+	}
+	return &ret
+}
+
+// WithWorkers set the number of keys reconciled at the same time, the same key is never reconciled twice at once.
+// Default DefaultWorkers.
+func WithWorkers(workers int) Option {
+	return func(o *options) {
+		if workers > 0 {
+			o.workers = workers
+		}
+	}
+}
+
+// WithBackoff set the exponential delay of the retries of a failing key, from base doubling up to max.
+// Default DefaultBackoffBase and DefaultBackoffMax.
+func WithBackoff(base, max time.Duration) Option {
+	return func(o *options) {
+		o.backoffBase = base
+		o.backoffMax = max
+	}
+}
+
+// WithDrainTimeout set the longest time Start waits for the queued keys once its context is done,
+// the context of the running reconciles is cancelled after it. Only used by NewManager.
+// Default DefaultDrainTimeout.
+func WithDrainTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.drainTimeout = timeout
+	}
+}
+
+// WithNamespace reconcile only the objects under namespace, all namespaces by default.
+func WithNamespace(namespace string) Option {
+	return func(o *options) {
+		o.namespace = namespace
+	}
+}
+
+// WithSelector reconcile only the objects matching selector, such as the objects of one tenant.
+func WithSelector(selector ggp.Selector) Option {
+	return func(o *options) {
+		o.selector = selector
+	}
+}
+
+// WithWatch enqueue the keys mapFunc returns for the changes of kind, such as a ConfigMap enqueuing its users.
+func WithWatch(kind string, mapFunc MapFunc) Option {
+	return func(o *options) {
+		o.watches = append(o.watches, watch{kind: kind, mapFunc: mapFunc})
+	}
+}
+
+// WithOwned enqueue the owner of the reconciled kind of the objects of kind, following their controller
+// owner references through the caches, such as a Pod enqueuing the Deployment owning its ReplicaSet.
+func WithOwned(kind string) Option {
+	return func(o *options) {
+		o.watches = append(o.watches, watch{kind: kind})
+	}
+}
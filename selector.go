@@ -0,0 +1,80 @@
+/*
+Copyright 2021 The Beijing Gridsum Technology Co., Ltd Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ggp
+
+import (
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Selector is the label and field selector used to query the k8s caches.
+// A nil Label or Field matches everything.
+type Selector struct {
+	// Label such as "app=mqtt,tier in (edge,cloud),!canary".
+	Label labels.Selector
+	// Field such as "spec.nodeName=node1,status.phase!=Failed".
+	Field fields.Selector
+}
+
+// Everything return the selector matching all objects.
+func Everything() Selector {
+	return Selector{Label: labels.Everything(), Field: fields.Everything()}
+}
+
+// ParseSelector return the selector parsed from the label and field selector strings, empty matches everything.
+func ParseSelector(label, field string) (Selector, error) {
+	l, err := labels.Parse(label)
+	if err != nil {
+		return Selector{}, err
+	}
+	f, err := fields.ParseSelector(field)
+	if err != nil {
+		return Selector{}, err
+	}
+	return Selector{Label: l, Field: f}, nil
+}
+
+// SelectorFromSet return the selector matching the objects whose labels contain every pair of set.
+func SelectorFromSet(set map[string]string) Selector {
+	return Selector{Label: labels.SelectorFromSet(set), Field: fields.Everything()}
+}
+
+// LabelSelector return the label selector, never nil.
+func (s Selector) LabelSelector() labels.Selector {
+	if s.Label == nil {
+		return labels.Everything()
+	}
+	return s.Label
+}
+
+// FieldSelector return the field selector, never nil.
+func (s Selector) FieldSelector() fields.Selector {
+	if s.Field == nil {
+		return fields.Everything()
+	}
+	return s.Field
+}
+
+// Matches return true if both the labels and the fields match.
+func (s Selector) Matches(l labels.Labels, f fields.Fields) bool {
+	return s.LabelSelector().Matches(l) && s.FieldSelector().Matches(f)
+}
+
+// String return the selector in the "label;field" form, used for logs.
+func (s Selector) String() string {
+	return s.LabelSelector().String() + ";" + s.FieldSelector().String()
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workload
+
+import (
+	"encoding/json"
+	"fmt"
+	istioscheme "istio.io/client-go/pkg/clientset/versioned/scheme"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	extensions "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/cache"
+	"reflect"
+)
+
+// The Ingress and HorizontalPodAutoscaler are informed in networking.k8s.io/v1 and autoscaling/v2 when served,
+// in extensions/v1beta1 and autoscaling/v2beta2 otherwise. The objects of the older versions are converted once
+// by the list watch of their informer, so the stores, the listers and the queries hold networking.k8s.io/v1
+// and autoscaling/v2 only, the version neutral view.
+
+// conversion convert the objects of a resource to the version neutral view of its kind, see kindResource.
+type conversion struct {
+	// objType is the version neutral object, such as *networkingv1.Ingress.
+	objType runtime.Object
+	// newList return the empty list of objType, such as *networkingv1.IngressList.
+	newList func() runtime.Object
+	// convert return the version neutral view of obj.
+	convert func(obj runtime.Object) (runtime.Object, error)
+}
+
+// ingressConversion convert the extensions/v1beta1 Ingresses to networking.k8s.io/v1.
+var ingressConversion = &conversion{
+	objType: &networkingv1.Ingress{},
+	newList: func() runtime.Object { return &networkingv1.IngressList{} },
+	convert: func(obj runtime.Object) (runtime.Object, error) {
+		ing, ok := obj.(*extensions.Ingress)
+		if !ok {
+			return nil, fmt.Errorf("convert %T, want *v1beta1.Ingress", obj)
+		}
+		return convertIngress(ing), nil
+	},
+}
+
+// horizontalPodAutoscalerConversion convert the autoscaling/v2beta2 HorizontalPodAutoscalers to autoscaling/v2.
+var horizontalPodAutoscalerConversion = &conversion{
+	objType: &autoscalingv2.HorizontalPodAutoscaler{},
+	newList: func() runtime.Object { return &autoscalingv2.HorizontalPodAutoscalerList{} },
+	convert: func(obj runtime.Object) (runtime.Object, error) {
+		hpa, ok := obj.(*autoscalingv2beta2.HorizontalPodAutoscaler)
+		if !ok {
+			return nil, fmt.Errorf("convert %T, want *v2beta2.HorizontalPodAutoscaler", obj)
+		}
+		return convertHorizontalPodAutoscaler(hpa)
+	},
+}
+
+// convertingListWatch return lw whose listed and watched objects are converted by c before they reach the store.
+func convertingListWatch(lw *cache.ListWatch, c *conversion) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			list, err := lw.List(options)
+			if err != nil {
+				return nil, err
+			}
+			items, err := meta.ExtractList(list)
+			if err != nil {
+				return nil, err
+			}
+			for i := range items {
+				if items[i], err = c.convert(items[i]); err != nil {
+					return nil, err
+				}
+			}
+			in, err := meta.ListAccessor(list)
+			if err != nil {
+				return nil, err
+			}
+			out := c.newList()
+			outMeta, err := meta.ListAccessor(out)
+			if err != nil {
+				return nil, err
+			}
+			outMeta.SetResourceVersion(in.GetResourceVersion())
+			outMeta.SetContinue(in.GetContinue())
+			outMeta.SetRemainingItemCount(in.GetRemainingItemCount())
+			if err := meta.SetList(out, items); err != nil {
+				return nil, err
+			}
+			return out, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			w, err := lw.Watch(options)
+			if err != nil {
+				return nil, err
+			}
+			// the bookmarks are converted as well, the reflector drops the objects not of the informed type.
+			return watch.Filter(w, func(event watch.Event) (watch.Event, bool) {
+				if event.Type == watch.Error {
+					return event, true
+				}
+				obj, err := c.convert(event.Object)
+				if err != nil {
+					return errorEvent(err), true
+				}
+				event.Object = obj
+				return event, true
+			}), nil
+		},
+	}
+}
+
+// convertIngress convert the extensions/v1beta1 Ingress to networking.k8s.io/v1.
+func convertIngress(in *extensions.Ingress) *networkingv1.Ingress {
+	out := &networkingv1.Ingress{
+		ObjectMeta: in.ObjectMeta,
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: in.Spec.IngressClassName,
+		},
+		Status: networkingv1.IngressStatus{LoadBalancer: in.Status.LoadBalancer},
+	}
+	if in.Spec.Backend != nil {
+		backend := convertIngressBackend(*in.Spec.Backend)
+		out.Spec.DefaultBackend = &backend
+	}
+	for _, tls := range in.Spec.TLS {
+		out.Spec.TLS = append(out.Spec.TLS, networkingv1.IngressTLS{Hosts: tls.Hosts, SecretName: tls.SecretName})
+	}
+	for _, rule := range in.Spec.Rules {
+		r := networkingv1.IngressRule{Host: rule.Host}
+		if rule.HTTP != nil {
+			r.HTTP = &networkingv1.HTTPIngressRuleValue{}
+			for _, path := range rule.HTTP.Paths {
+				p := networkingv1.HTTPIngressPath{Path: path.Path, Backend: convertIngressBackend(path.Backend)}
+				if path.PathType != nil {
+					pathType := networkingv1.PathType(*path.PathType)
+					p.PathType = &pathType
+				}
+				r.HTTP.Paths = append(r.HTTP.Paths, p)
+			}
+		}
+		out.Spec.Rules = append(out.Spec.Rules, r)
+	}
+	return out
+}
+
+// convertIngressBackend convert the service name and port to the service backend.
+func convertIngressBackend(in extensions.IngressBackend) networkingv1.IngressBackend {
+	out := networkingv1.IngressBackend{Resource: in.Resource}
+	if in.ServiceName != "" {
+		out.Service = &networkingv1.IngressServiceBackend{Name: in.ServiceName}
+		if in.ServicePort.Type == intstr.String {
+			out.Service.Port.Name = in.ServicePort.StrVal
+		} else {
+			out.Service.Port.Number = in.ServicePort.IntVal
+		}
+	}
+	return out
+}
+
+// convertHorizontalPodAutoscaler convert the autoscaling/v2beta2 HorizontalPodAutoscaler to autoscaling/v2.
+// autoscaling/v2 is autoscaling/v2beta2 graduated, the fields are the same.
+func convertHorizontalPodAutoscaler(in *autoscalingv2beta2.HorizontalPodAutoscaler) (*autoscalingv2.HorizontalPodAutoscaler, error) {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	out := &autoscalingv2.HorizontalPodAutoscaler{}
+	if err := json.Unmarshal(data, out); err != nil {
+		return nil, err
+	}
+	out.APIVersion, out.Kind = "", ""
+	return out, nil
+}
+
+// fromMetadata return the typed object of gvk holding the metadata of the metadata only item,
+// such as a *corev1.Secret without data, the item itself if it is not metadata only.
+func fromMetadata(gvk schema.GroupVersionKind, item interface{}) interface{} {
+	partial, ok := item.(*metav1.PartialObjectMetadata)
+	if !ok {
+		return item
+	}
+	obj, err := scheme.Scheme.New(gvk)
+	if err != nil {
+		if obj, err = istioscheme.Scheme.New(gvk); err != nil {
+			return item
+		}
+	}
+	meta := reflect.ValueOf(obj).Elem().FieldByName("ObjectMeta")
+	if !meta.IsValid() || !meta.CanSet() {
+		return item
+	}
+	meta.Set(reflect.ValueOf(partial.ObjectMeta))
+	return obj
+}
@@ -0,0 +1,242 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workload
+
+import (
+	"context"
+	"errors"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	extensions "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+	metadatafake "k8s.io/client-go/metadata/fake"
+	"testing"
+	"x6t.io/ggp"
+)
+
+// newMockVersionedClientset return the clientset of an apiserver serving Ingress and HorizontalPodAutoscaler
+// in the group versions.
+func newMockVersionedClientset(groupVersions []string, objects ...runtime.Object) *fake.Clientset {
+	clientset := fake.NewSimpleClientset(objects...)
+	for _, gv := range groupVersions {
+		clientset.Resources = append(clientset.Resources, &metav1.APIResourceList{
+			GroupVersion: gv,
+			APIResources: []metav1.APIResource{{Name: "ingresses"}, {Name: "horizontalpodautoscalers"}},
+		})
+	}
+	return clientset
+}
+
+// newMockVersionedController return the started controller of Ingress and HorizontalPodAutoscaler
+// against an apiserver serving the resources of the group versions.
+func newMockVersionedController(t *testing.T, groupVersions []string, objects ...runtime.Object) *controller {
+	stopCh := make(chan struct{})
+	t.Cleanup(func() { close(stopCh) })
+	c := NewController(newMockVersionedClientset(groupVersions, objects...), stopCh, WithResources(Ingress, HorizontalPodAutoscaler)).(*controller)
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// discoveries return the number of discovery requests sent to clientset.
+func discoveries(clientset *fake.Clientset) int {
+	n := 0
+	for _, action := range clientset.Actions() {
+		if resource := action.GetResource().Resource; action.GetVerb() == "get" && (resource == "group" || resource == "resource") {
+			n++
+		}
+	}
+	return n
+}
+
+// groupVersions return the informed group version of Ingress and HorizontalPodAutoscaler.
+func groupVersions(c *controller) (string, string) {
+	versions := map[string]string{}
+	for _, status := range c.SyncStatus() {
+		versions[status.Name] = status.GroupVersion
+	}
+	return versions["Ingress"], versions["HorizontalPodAutoscaler"]
+}
+
+func TestVersionedKinds(t *testing.T) {
+	meta := metav1.ObjectMeta{Namespace: "edge", Name: "mqtt"}
+	minReplicas := int32(2)
+
+	tests := []struct {
+		name          string
+		groupVersions []string
+		objects       []runtime.Object
+		wantIngress   string
+		wantHPA       string
+	}{
+		{
+			name:          "served",
+			groupVersions: []string{"networking.k8s.io/v1", "extensions/v1beta1", "autoscaling/v2", "autoscaling/v2beta2"},
+			objects: []runtime.Object{
+				&networkingv1.Ingress{ObjectMeta: meta, Spec: networkingv1.IngressSpec{DefaultBackend: &networkingv1.IngressBackend{
+					Service: &networkingv1.IngressServiceBackend{Name: "mqtt", Port: networkingv1.ServiceBackendPort{Number: 1883}},
+				}}},
+				&autoscalingv2.HorizontalPodAutoscaler{ObjectMeta: meta, Spec: autoscalingv2.HorizontalPodAutoscalerSpec{MinReplicas: &minReplicas, MaxReplicas: 5}},
+			},
+			wantIngress: "networking.k8s.io/v1",
+			wantHPA:     "autoscaling/v2",
+		},
+		{
+			name:          "legacy",
+			groupVersions: []string{"extensions/v1beta1", "autoscaling/v2beta2"},
+			objects: []runtime.Object{
+				&extensions.Ingress{ObjectMeta: meta, Spec: extensions.IngressSpec{Backend: &extensions.IngressBackend{
+					ServiceName: "mqtt", ServicePort: intstr.FromInt(1883),
+				}}},
+				&autoscalingv2beta2.HorizontalPodAutoscaler{ObjectMeta: meta, Spec: autoscalingv2beta2.HorizontalPodAutoscalerSpec{MinReplicas: &minReplicas, MaxReplicas: 5}},
+			},
+			wantIngress: "extensions/v1beta1",
+			wantHPA:     "autoscaling/v2beta2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockVersionedController(t, tt.groupVersions, tt.objects...)
+			if ingress, hpa := groupVersions(c); ingress != tt.wantIngress || hpa != tt.wantHPA {
+				t.Errorf("SyncStatus() versions = %s and %s, want %s and %s", ingress, hpa, tt.wantIngress, tt.wantHPA)
+			}
+
+			ing, err := c.GetIngress("edge", "mqtt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if backend := ing.Spec.DefaultBackend; backend == nil || backend.Service.Name != "mqtt" || backend.Service.Port.Number != 1883 {
+				t.Errorf("GetIngress() backend = %+v, want mqtt:1883", backend)
+			}
+			hpa, err := c.GetHorizontalPodAutoscaler("edge", "mqtt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *hpa.Spec.MinReplicas != 2 || hpa.Spec.MaxReplicas != 5 {
+				t.Errorf("GetHorizontalPodAutoscaler() spec = %+v, want 2 to 5 replicas", hpa.Spec)
+			}
+			items, err := c.List("Ingress", "edge", ggp.Everything())
+			if err != nil || len(items) != 1 {
+				t.Fatalf("List() = %v, %v, want 1 Ingress", items, err)
+			}
+			if _, ok := items[0].(*networkingv1.Ingress); !ok {
+				t.Errorf("List() = %T, want *networkingv1.Ingress", items[0])
+			}
+			if _, err := c.GetIngress("edge", "broker"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetIngress(broker) error = %v, want ErrNotFound", err)
+			}
+
+			// the listers are typed as the version neutral view, the stores are converted once.
+			listed, err := c.IngressLister().Ingresses("edge").Get("mqtt")
+			if err != nil || listed != ing {
+				t.Errorf("IngressLister() Get() = %v, %v, want the cached %v", listed, err, ing)
+			}
+			listedHPA, err := c.HorizontalPodAutoscalerLister().HorizontalPodAutoscalers("edge").Get("mqtt")
+			if err != nil || listedHPA != hpa {
+				t.Errorf("HorizontalPodAutoscalerLister() Get() = %v, %v, want the cached %v", listedHPA, err, hpa)
+			}
+			if again, _ := c.GetHorizontalPodAutoscaler("edge", "mqtt"); again != hpa {
+				t.Errorf("GetHorizontalPodAutoscaler() converted the cached object again")
+			}
+		})
+	}
+}
+
+func TestVersionsDiscoveredByStart(t *testing.T) {
+	clientset := newMockVersionedClientset([]string{"extensions/v1beta1", "autoscaling/v2beta2"},
+		&extensions.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "edge", Name: "mqtt"}})
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	c := NewController(clientset, stopCh, WithResources(Ingress, HorizontalPodAutoscaler)).(*controller)
+	if n := discoveries(clientset); n != 0 {
+		t.Errorf("NewController() sent %d discovery requests, want none", n)
+	}
+	if ingress, hpa := groupVersions(c); ingress != "networking.k8s.io/v1" || hpa != "autoscaling/v2" {
+		t.Errorf("versions before Start = %s and %s, want the preferred ones", ingress, hpa)
+	}
+
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if ingress, hpa := groupVersions(c); ingress != "extensions/v1beta1" || hpa != "autoscaling/v2beta2" {
+		t.Errorf("versions once started = %s and %s, want the served ones", ingress, hpa)
+	}
+	if _, err := c.GetIngress("edge", "mqtt"); err != nil {
+		t.Errorf("GetIngress() error = %v", err)
+	}
+
+	// Stop keeps the resolved versions without discovering them again.
+	n := discoveries(clientset)
+	c.Stop()
+	if got := discoveries(clientset); got != n {
+		t.Errorf("Stop() sent %d discovery requests, want none", got-n)
+	}
+	if ingress, _ := groupVersions(c); ingress != "extensions/v1beta1" {
+		t.Errorf("versions once stopped = %s, want the served one", ingress)
+	}
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetIngress("edge", "mqtt"); err != nil {
+		t.Errorf("GetIngress() once started again error = %v", err)
+	}
+}
+
+func TestMetadataOnly(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := metav1.AddMetaToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	secret := &metav1.PartialObjectMetadata{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "edge", Name: "mqtt-tls", Labels: map[string]string{"app": "mqtt"}},
+	}
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	c := NewController(fake.NewSimpleClientset(), stopCh,
+		WithResources(Secret, ConfigMap),
+		WithMetadataClient(metadatafake.NewSimpleMetadataClient(scheme, secret)),
+		WithMetadataOnly(Secret)).(*controller)
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.GetSecret("edge", "mqtt-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Labels["app"] != "mqtt" || got.Data != nil {
+		t.Errorf("GetSecret() = %+v, want the metadata only", got)
+	}
+	secrets, err := c.ListSecrets("edge", ggp.SelectorFromSet(map[string]string{"app": "mqtt"}))
+	if err != nil || len(secrets) != 1 {
+		t.Errorf("ListSecrets() = %v, %v, want 1 Secret", secrets, err)
+	}
+	if _, err := c.SecretLister().Secrets("edge").Get("mqtt-tls"); !errors.Is(err, ErrMetadataOnly) {
+		t.Errorf("SecretLister() Get() error = %v, want ErrMetadataOnly for the metadata only kinds", err)
+	}
+	for _, status := range c.SyncStatus() {
+		if status.MetadataOnly != (status.Name == "Secret") {
+			t.Errorf("SyncStatus() %s metadata only = %v", status.Name, status.MetadataOnly)
+		}
+	}
+}
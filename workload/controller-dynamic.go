@@ -0,0 +1,228 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workload
+
+import (
+	"context"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"sort"
+	"sync"
+	"x6t.io/ggp"
+)
+
+// dynamicInformers is the informers of the resources registered by RegisterDynamic,
+// guarded by their own lock as they are added to running informers.
+type dynamicInformers struct {
+	mu        sync.RWMutex
+	informers map[schema.GroupVersionResource]cache.SharedIndexInformer
+}
+
+// Dynamic return the informer of the registered resource, nil if it is not registered.
+func (i *Informer) Dynamic(gvr schema.GroupVersionResource) cache.SharedIndexInformer {
+	if i.dynamic == nil {
+		return nil
+	}
+	i.dynamic.mu.RLock()
+	defer i.dynamic.mu.RUnlock()
+	return i.dynamic.informers[gvr]
+}
+
+// setDynamic assign the informer of the registered resource.
+func (i *Informer) setDynamic(gvr schema.GroupVersionResource, informer cache.SharedIndexInformer) {
+	if i.dynamic == nil {
+		i.dynamic = &dynamicInformers{}
+	}
+	i.dynamic.mu.Lock()
+	defer i.dynamic.mu.Unlock()
+	if i.dynamic.informers == nil {
+		i.dynamic.informers = map[schema.GroupVersionResource]cache.SharedIndexInformer{}
+	}
+	i.dynamic.informers[gvr] = informer
+}
+
+// eachDynamic call fn with every informer of the registered resources, sorted by name.
+func (i *Informer) eachDynamic(fn func(gvr schema.GroupVersionResource, informer cache.SharedIndexInformer)) {
+	if i.dynamic == nil {
+		return
+	}
+	i.dynamic.mu.RLock()
+	gvrs := make([]schema.GroupVersionResource, 0, len(i.dynamic.informers))
+	informers := make(map[schema.GroupVersionResource]cache.SharedIndexInformer, len(i.dynamic.informers))
+	for gvr, informer := range i.dynamic.informers {
+		gvrs = append(gvrs, gvr)
+		informers[gvr] = informer
+	}
+	i.dynamic.mu.RUnlock()
+	sort.Slice(gvrs, func(a, b int) bool { return dynamicName(gvrs[a]) < dynamicName(gvrs[b]) })
+	for _, gvr := range gvrs {
+		fn(gvr, informers[gvr])
+	}
+}
+
+// dynamicName return the name of the registered resource in the sync status and the query errors,
+// the resource and the group like kubectl, such as devices.devices.kubeedge.io.
+func dynamicName(gvr schema.GroupVersionResource) string {
+	return gvr.GroupResource().String()
+}
+
+// RegisterDynamic inform the resource gvr through the dynamic client, such as a CRD, the objects are *unstructured.Unstructured.
+// A namespaced resource is informed under WithNamespace and filtered by WithNamespaceSelector like the other kinds,
+// the tweaks, resync period and transforms given for every kind apply as well.
+// The informer runs at once if the controller is running, call Start to wait for it to sync, and is kept by Restart.
+// Registering the resource again is a no-op, it needs WithDynamicClient or NewManagerController.
+func (c *controller) RegisterDynamic(gvr schema.GroupVersionResource, namespaced bool) error {
+	select {
+	case <-c.stopCh:
+		return ErrStopped
+	default:
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.options.dynamicClient == nil {
+		return ErrNoDynamicClient
+	}
+	if registered, ok := c.dynamicResources[gvr]; ok {
+		if registered != namespaced {
+			return fmt.Errorf("register %s: already registered with namespaced %v", dynamicName(gvr), registered)
+		}
+		return nil
+	}
+	if c.dynamicResources == nil {
+		c.dynamicResources = map[schema.GroupVersionResource]bool{}
+	}
+	c.dynamicResources[gvr] = namespaced
+	informer := c.buildDynamic(c.informers, gvr, namespaced)
+	if c.runCh != nil {
+		go informer.Run(c.runCh)
+	}
+	return nil
+}
+
+// buildDynamic create the informer of the registered resource into informers, with the event handler.
+func (c *controller) buildDynamic(informers *Informer, gvr schema.GroupVersionResource, namespaced bool) cache.SharedIndexInformer {
+	o := c.options
+	namespace := corev1.NamespaceAll
+	if namespaced {
+		namespace = o.namespace
+	}
+	indexers := DefaultIndexers()
+	indexers[NamespaceIndex] = cache.MetaNamespaceIndexFunc
+	resync, tweak := o.resyncFor(allKinds), o.tweakFor(allKinds)
+
+	var informer cache.SharedIndexInformer
+	if transform := o.transformFor(allKinds); transform != nil {
+		lw := transformingListWatch(newDynamicListWatch(o.dynamicClient, gvr, namespace), tweak, transform)
+		informer = cache.NewSharedIndexInformer(lw, &unstructured.Unstructured{}, resync, indexers)
+	} else {
+		informer = dynamicinformer.NewFilteredDynamicInformer(o.dynamicClient, gvr, namespace, resync, indexers, tweak).Informer()
+	}
+	informer.AddEventHandlerWithResyncPeriod(cache.FilteringResourceEventHandler{
+		FilterFunc: c.inScope,
+		Handler:    currentHandler{c: c, informers: informers},
+	}, resync)
+	informers.setDynamic(gvr, informer)
+	return informer
+}
+
+// newDynamicListWatch return the list watch of gvr through the dynamic client.
+func newDynamicListWatch(client dynamic.Interface, gvr schema.GroupVersionResource, namespace string) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return client.Resource(gvr).Namespace(namespace).List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.Resource(gvr).Namespace(namespace).Watch(context.TODO(), options)
+		},
+	}
+}
+
+// dynamicIndexer return the synced store of the registered resource, the namespace must be watched.
+func (c *controller) dynamicIndexer(gvr schema.GroupVersionResource, namespace string) (cache.Indexer, error) {
+	informer := c.getInformers().Dynamic(gvr)
+	if informer == nil {
+		return nil, &QueryError{Kind: dynamicName(gvr), Namespace: namespace, Err: ErrKindDisabled}
+	}
+	if !informer.HasSynced() {
+		return nil, &QueryError{Kind: dynamicName(gvr), Namespace: namespace, Err: ErrNotSynced}
+	}
+	if !c.namespaceWatched(namespace) {
+		return nil, &QueryError{Kind: dynamicName(gvr), Namespace: namespace, Err: ErrNamespaceNotWatched}
+	}
+	return informer.GetIndexer(), nil
+}
+
+// DynamicLister return the lister of the registered resource, nil if it is not registered.
+// The lister is replaced by Stop and Restart, get it again after them.
+func (c *controller) DynamicLister(gvr schema.GroupVersionResource) cache.GenericLister {
+	informer := c.getInformers().Dynamic(gvr)
+	if informer == nil {
+		return nil
+	}
+	return cache.NewGenericLister(informer.GetIndexer(), gvr.GroupResource())
+}
+
+// ListDynamic return the objects of the registered resource under this namespace matching the selector,
+// the field selectors support metadata.name and metadata.namespace.
+func (c *controller) ListDynamic(gvr schema.GroupVersionResource, namespace string, selector ggp.Selector) ([]*unstructured.Unstructured, error) {
+	indexer, err := c.dynamicIndexer(gvr, namespace)
+	if err != nil {
+		return nil, err
+	}
+	items, err := selectFrom(indexer, namespace, selector)
+	if err != nil {
+		return nil, err
+	}
+	return toUnstructured(c.filterScope(items)), nil
+}
+
+// GetDynamic return the object of the registered resource based on the namespace and name,
+// the namespace is empty for cluster scoped resources.
+func (c *controller) GetDynamic(gvr schema.GroupVersionResource, namespace, name string) (*unstructured.Unstructured, error) {
+	indexer, err := c.dynamicIndexer(gvr, namespace)
+	if err != nil {
+		return nil, err
+	}
+	item, exists, err := indexer.GetByKey(namespaceKey(namespace, name))
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := item.(*unstructured.Unstructured)
+	if !exists || !ok || !c.inScope(obj) {
+		return nil, &QueryError{Kind: dynamicName(gvr), Namespace: namespace, Name: name, Err: ErrNotFound}
+	}
+	return obj, nil
+}
+
+// toUnstructured convert the items of a dynamic store.
+func toUnstructured(items []interface{}) []*unstructured.Unstructured {
+	ret := make([]*unstructured.Unstructured, 0, len(items))
+	for _, item := range items {
+		if obj, ok := item.(*unstructured.Unstructured); ok {
+			ret = append(ret, obj)
+		}
+	}
+	return ret
+}
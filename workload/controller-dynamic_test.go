@@ -0,0 +1,114 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workload_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"x6t.io/ggp"
+	"x6t.io/ggp/ggptest"
+	"x6t.io/ggp/workload"
+)
+
+var devices = schema.GroupVersionResource{Group: "devices.kubeedge.io", Version: "v1alpha2", Resource: "devices"}
+
+func newMockDevice(name, model string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("devices.kubeedge.io/v1alpha2")
+	u.SetKind("Device")
+	u.SetNamespace("edge")
+	u.SetName(name)
+	u.SetLabels(map[string]string{"model": model})
+	return u
+}
+
+func TestRegisterDynamic(t *testing.T) {
+	h := ggptest.NewHarness(t, []runtime.Object{newMockDevice("sensor-0", "dht11")}, workload.WithResources(workload.Pod))
+	c := h.Controller
+	if _, err := c.ListDynamic(devices, "edge", ggp.Everything()); !errors.Is(err, workload.ErrKindDisabled) {
+		t.Errorf("ListDynamic() before RegisterDynamic error = %v, want ErrKindDisabled", err)
+	}
+	if err := c.RegisterDynamic(devices, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RegisterDynamic(devices, false); err == nil {
+		t.Errorf("RegisterDynamic() with another scope error = nil")
+	}
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	device, err := c.GetDynamic(devices, "edge", "sensor-0")
+	if err != nil || device.GetLabels()["model"] != "dht11" {
+		t.Fatalf("GetDynamic() = %v, %v, want sensor-0", device, err)
+	}
+	var status *ggp.SyncStatus
+	for _, s := range c.SyncStatus() {
+		if s.Name == "devices.devices.kubeedge.io" {
+			s := s
+			status = &s
+		}
+	}
+	if status == nil || !status.Synced || status.GroupVersion != "devices.kubeedge.io/v1alpha2" {
+		t.Errorf("SyncStatus() devices = %+v, want synced in devices.kubeedge.io/v1alpha2", status)
+	}
+
+	h.Create(newMockDevice("sensor-1", "ds18b20"), newMockDevice("sensor-2", "dht11"))
+	dht11 := ggp.SelectorFromSet(map[string]string{"model": "dht11"})
+	h.Eventually(func() error {
+		got, err := c.ListDynamic(devices, "edge", dht11)
+		if err != nil || len(got) != 2 {
+			return fmt.Errorf("ListDynamic() = %d devices, %v, want 2", len(got), err)
+		}
+		return nil
+	})
+	objs, err := c.DynamicLister(devices).ByNamespace("edge").List(labels.Everything())
+	if err != nil || len(objs) != 3 {
+		t.Errorf("DynamicLister() = %d objects, %v, want 3", len(objs), err)
+	}
+
+	// the registered resources are informed again after a restart.
+	h.Delete(newMockDevice("sensor-0", "dht11"))
+	if err := c.Restart(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetDynamic(devices, "edge", "sensor-0"); !errors.Is(err, workload.ErrNotFound) {
+		t.Errorf("GetDynamic() deleted error = %v, want ErrNotFound", err)
+	}
+	if _, err := c.GetDynamic(devices, "edge", "sensor-1"); err != nil {
+		t.Errorf("GetDynamic() after Restart error = %v", err)
+	}
+}
+
+func TestRegisterDynamicWithoutClient(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	c := workload.NewController(fake.NewSimpleClientset(), stopCh, workload.WithResources(workload.Pod))
+	if err := c.RegisterDynamic(devices, true); !errors.Is(err, workload.ErrNoDynamicClient) {
+		t.Errorf("RegisterDynamic() error = %v, want ErrNoDynamicClient", err)
+	}
+}
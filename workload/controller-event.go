@@ -0,0 +1,270 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workload
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"sort"
+	"sync"
+	"time"
+	"x6t.io/ggp"
+)
+
+const (
+	// DefaultEventBufferSize is default number of events kept for one object.
+	DefaultEventBufferSize = 32
+	// DefaultEventTTL is default time an event is kept after its last occurrence.
+	DefaultEventTTL = time.Hour
+	// DefaultEventEvictPeriod is default period of the expired events eviction.
+	DefaultEventEvictPeriod = time.Minute
+)
+
+// eventTimeline is the ring buffer of the events of one object, saved in cachesMap.
+type eventTimeline struct {
+	mu sync.Mutex
+	// buf holds at most size events, buf[start] is the oldest once it is full.
+	buf   []*corev1.Event
+	start int
+	size  int
+	// removed is set once the timeline is removed from cachesMap, writers must load a new one.
+	removed bool
+}
+
+// put insert the event, replacing the one with the same name, the oldest is overwritten once full.
+func (t *eventTimeline) put(event *corev1.Event) {
+	for i, e := range t.buf {
+		if e.Name == event.Name {
+			t.buf[i] = event
+			return
+		}
+	}
+	if len(t.buf) < t.size {
+		t.buf = append(t.buf, event)
+		return
+	}
+	t.buf[t.start] = event
+	t.start = (t.start + 1) % t.size
+}
+
+// remove drop the events matching fn.
+func (t *eventTimeline) remove(fn func(event *corev1.Event) bool) {
+	buf := make([]*corev1.Event, 0, len(t.buf))
+	for _, e := range t.ordered() {
+		if !fn(e) {
+			buf = append(buf, e)
+		}
+	}
+	t.buf, t.start = buf, 0
+}
+
+// ordered return the events from the oldest inserted.
+func (t *eventTimeline) ordered() []*corev1.Event {
+	return append(append(make([]*corev1.Event, 0, len(t.buf)), t.buf[t.start:]...), t.buf[:t.start]...)
+}
+
+// eventTime return the last time the event occurred.
+func eventTime(event *corev1.Event) time.Time {
+	switch {
+	case event.Series != nil && !event.Series.LastObservedTime.IsZero():
+		return event.Series.LastObservedTime.Time
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	default:
+		return event.CreationTimestamp.Time
+	}
+}
+
+// eventFirstTime return the first time the event occurred.
+func eventFirstTime(event *corev1.Event) time.Time {
+	switch {
+	case !event.FirstTimestamp.IsZero():
+		return event.FirstTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	default:
+		return eventTime(event)
+	}
+}
+
+// eventCount return how many times the event occurred.
+func eventCount(event *corev1.Event) int32 {
+	switch {
+	case event.Series != nil:
+		return event.Series.Count
+	case event.Count > 0:
+		return event.Count
+	default:
+		return 1
+	}
+}
+
+// eventKey return the cachesMap key of the timeline of the object.
+func (c *controller) eventKey(namespace, kind, name string) string {
+	return c.Prefix(Event, namespace, kind, name)
+}
+
+// putEvent insert the event into the timeline of its involved object.
+func (c *controller) putEvent(event *corev1.Event) {
+	if c.expired(event, time.Now()) {
+		return
+	}
+	key := c.eventKey(event.Namespace, event.InvolvedObject.Kind, event.InvolvedObject.Name)
+	for {
+		v, _ := c.cachesMap.LoadOrStore(key, &eventTimeline{size: c.options.eventBufferSize})
+		timeline := v.(*eventTimeline)
+		timeline.mu.Lock()
+		if !timeline.removed {
+			timeline.put(event)
+			timeline.mu.Unlock()
+			return
+		}
+		timeline.mu.Unlock()
+	}
+}
+
+// deleteEvent remove the event from the timeline of its involved object.
+func (c *controller) deleteEvent(event *corev1.Event) {
+	key := c.eventKey(event.Namespace, event.InvolvedObject.Kind, event.InvolvedObject.Name)
+	c.updateTimeline(key, func(timeline *eventTimeline) {
+		timeline.remove(func(e *corev1.Event) bool {
+			return e.Name == event.Name
+		})
+	})
+}
+
+// updateTimeline run fn on the timeline saved under key, the timeline is removed once empty.
+func (c *controller) updateTimeline(key interface{}, fn func(timeline *eventTimeline)) {
+	v, ok := c.cachesMap.Load(key)
+	if !ok {
+		return
+	}
+	timeline, ok := v.(*eventTimeline)
+	if !ok {
+		return
+	}
+	timeline.mu.Lock()
+	defer timeline.mu.Unlock()
+	fn(timeline)
+	if len(timeline.buf) == 0 && !timeline.removed {
+		timeline.removed = true
+		c.cachesMap.Delete(key)
+	}
+}
+
+// expired return true if the event last occurred more than the event ttl before now.
+func (c *controller) expired(event *corev1.Event, now time.Time) bool {
+	return c.options.eventTTL > 0 && now.Sub(eventTime(event)) > c.options.eventTTL
+}
+
+// evictEvents remove the expired events of every timeline.
+func (c *controller) evictEvents() {
+	now := time.Now()
+	c.cachesMap.Range(func(key, v interface{}) bool {
+		if _, ok := v.(*eventTimeline); ok {
+			c.updateTimeline(key, func(timeline *eventTimeline) {
+				timeline.remove(func(e *corev1.Event) bool {
+					return c.expired(e, now)
+				})
+			})
+		}
+		return true
+	})
+}
+
+// events return the events of the object sorted by last timestamp, the oldest first.
+func (c *controller) events(namespace, kind, name string) ([]*corev1.Event, error) {
+	if _, err := c.indexer(Event, namespace); err != nil {
+		return nil, err
+	}
+	v, ok := c.cachesMap.Load(c.eventKey(namespace, kind, name))
+	if !ok {
+		return []*corev1.Event{}, nil
+	}
+	timeline := v.(*eventTimeline)
+	timeline.mu.Lock()
+	events := timeline.ordered()
+	timeline.mu.Unlock()
+	now := time.Now()
+	ret := make([]*corev1.Event, 0, len(events))
+	for _, e := range events {
+		if !c.expired(e, now) {
+			ret = append(ret, e)
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return eventTime(ret[i]).Before(eventTime(ret[j]))
+	})
+	return ret, nil
+}
+
+// GetEvents return the events of the object grouped by reason, the groups and their events are sorted
+// by last timestamp, the oldest first. Such as GetEvents("default", "Pod", "mqtt-0").
+func (c *controller) GetEvents(namespace, kind, name string) ([]ggp.EventGroup, error) {
+	events, err := c.events(namespace, kind, name)
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]ggp.EventGroup, 0)
+	index := map[string]int{}
+	for _, e := range events {
+		i, ok := index[e.Reason]
+		if !ok {
+			i = len(groups)
+			index[e.Reason] = i
+			groups = append(groups, ggp.EventGroup{Reason: e.Reason, FirstTimestamp: eventFirstTime(e)})
+		}
+		g := &groups[i]
+		g.Type = e.Type
+		g.Message = e.Message
+		g.Count += eventCount(e)
+		g.LastTimestamp = eventTime(e)
+		if first := eventFirstTime(e); first.Before(g.FirstTimestamp) {
+			g.FirstTimestamp = first
+		}
+		g.Events = append(g.Events, e)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].LastTimestamp.Before(groups[j].LastTimestamp)
+	})
+	return groups, nil
+}
+
+// LatestWarning return the latest Warning event of the object, ErrNotFound if there is none.
+func (c *controller) LatestWarning(namespace, kind, name string) (*corev1.Event, error) {
+	events, err := c.events(namespace, kind, name)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Type == corev1.EventTypeWarning {
+			return events[i], nil
+		}
+	}
+	return nil, &QueryError{Kind: Event.String(), Namespace: namespace, Name: name, Err: ErrNotFound}
+}
+
+// GetPodEventMessage return the message of the latest event of the object, empty if there is none.
+// Deprecated: use GetEvents or LatestWarning.
+func (c *controller) GetPodEventMessage(namespace, kind, name string) string {
+	events, err := c.events(namespace, kind, name)
+	if err != nil || len(events) == 0 {
+		return ""
+	}
+	return events[len(events)-1].Message
+}
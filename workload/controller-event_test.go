@@ -0,0 +1,225 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workload
+
+import (
+	"context"
+	"errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newMockEvent(name, reason, eventType string, count int32, last time.Time) *corev1.Event {
+	return &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Namespace: "edge", Name: name},
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: "edge", Name: "mqtt-0"},
+		Reason:         reason,
+		Type:           eventType,
+		Message:        name,
+		Count:          count,
+		FirstTimestamp: metav1.NewTime(last.Add(-time.Minute)),
+		LastTimestamp:  metav1.NewTime(last),
+	}
+}
+
+func newMockEventController(t *testing.T, opts ...Option) *controller {
+	stopCh := make(chan struct{})
+	t.Cleanup(func() { close(stopCh) })
+	c := NewController(fake.NewSimpleClientset(), stopCh, append([]Option{WithResources(Event)}, opts...)...).(*controller)
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestGetEvents(t *testing.T) {
+	c := newMockEventController(t)
+	now := time.Now()
+	c.OnAdd(newMockEvent("pulled", "Pulled", corev1.EventTypeNormal, 1, now.Add(-4*time.Minute)))
+	c.OnAdd(newMockEvent("backoff", "BackOff", corev1.EventTypeWarning, 3, now.Add(-3*time.Minute)))
+	c.OnAdd(newMockEvent("started", "Started", corev1.EventTypeNormal, 1, now.Add(-2*time.Minute)))
+	c.OnUpdate(nil, newMockEvent("backoff", "BackOff", corev1.EventTypeWarning, 5, now.Add(-time.Minute)))
+	c.OnAdd(newMockEvent("backoff-2", "BackOff", corev1.EventTypeWarning, 2, now))
+	c.OnAdd(newMockEvent("expired", "Killing", corev1.EventTypeNormal, 1, now.Add(-2*DefaultEventTTL)))
+
+	groups, err := c.GetEvents("edge", "Pod", "mqtt-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, 0, len(groups))
+	for _, g := range groups {
+		got = append(got, g.Reason)
+	}
+	if want := []string{"Pulled", "Started", "BackOff"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("GetEvents() reasons = %v, want %v", got, want)
+	}
+	if backoff := groups[2]; backoff.Count != 7 || len(backoff.Events) != 2 || backoff.Message != "backoff-2" {
+		t.Errorf("GetEvents() BackOff = %+v, want count 7 with 2 events", backoff)
+	}
+
+	warning, err := c.LatestWarning("edge", "Pod", "mqtt-0")
+	if err != nil || warning.Name != "backoff-2" {
+		t.Errorf("LatestWarning() = %v, %v, want backoff-2", warning, err)
+	}
+	c.OnDelete(newMockEvent("backoff", "BackOff", corev1.EventTypeWarning, 5, now))
+	c.OnDelete(newMockEvent("backoff-2", "BackOff", corev1.EventTypeWarning, 2, now))
+	if _, err := c.LatestWarning("edge", "Pod", "mqtt-0"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("LatestWarning() error = %v, want ErrNotFound", err)
+	}
+	if got := c.GetPodEventMessage("edge", "Pod", "mqtt-0"); got != "started" {
+		t.Errorf("GetPodEventMessage() = %q, want started", got)
+	}
+}
+
+func TestEventBuffer(t *testing.T) {
+	c := newMockEventController(t, WithEventBuffer(2, time.Minute))
+	now := time.Now()
+	c.OnAdd(newMockEvent("a", "A", corev1.EventTypeNormal, 1, now.Add(-3*time.Second)))
+	c.OnAdd(newMockEvent("b", "B", corev1.EventTypeNormal, 1, now.Add(-2*time.Second)))
+	c.OnAdd(newMockEvent("c", "C", corev1.EventTypeNormal, 1, now.Add(-time.Second)))
+	groups, err := c.GetEvents("edge", "Pod", "mqtt-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 || groups[0].Reason != "B" || groups[1].Reason != "C" {
+		t.Errorf("GetEvents() = %+v, want the 2 latest events", groups)
+	}
+
+	c.options.eventTTL = time.Nanosecond
+	c.evictEvents()
+	if _, ok := c.cachesMap.Load(c.eventKey("edge", "Pod", "mqtt-0")); ok {
+		t.Errorf("evictEvents() kept the timeline of expired events")
+	}
+}
+
+func TestEventsOfReplacedInformers(t *testing.T) {
+	c := newMockEventController(t)
+	now := time.Now()
+	c.OnAdd(newMockEvent("pulled", "Pulled", corev1.EventTypeNormal, 1, now))
+	key := c.eventKey("edge", "Pod", "mqtt-0")
+	v, _ := c.cachesMap.Load(key)
+	timeline := v.(*eventTimeline)
+	c.mu.RLock()
+	replaced := currentHandler{c: c, informers: c.informers}
+	c.mu.RUnlock()
+
+	if err := c.Restart(nil); err != nil {
+		t.Fatal(err)
+	}
+	if !timeline.removed {
+		t.Errorf("Restart() kept the timeline, want it removed")
+	}
+	// the events of the replaced informers still in flight are not written into the new timelines.
+	replaced.OnAdd(newMockEvent("started", "Started", corev1.EventTypeNormal, 1, now))
+	if _, ok := c.cachesMap.Load(key); ok {
+		t.Errorf("the event of the replaced informers is cached after Restart()")
+	}
+	current := currentHandler{c: c, informers: c.getInformers()}
+	current.OnAdd(newMockEvent("started", "Started", corev1.EventTypeNormal, 1, now))
+	if _, ok := c.cachesMap.Load(key); !ok {
+		t.Errorf("the event of the current informers is not cached")
+	}
+}
+
+func newCachedEvent(namespace, name, pod, message string) *corev1.Event {
+	return &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Namespace: namespace, Name: name},
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: namespace, Name: pod},
+		Message:        message,
+		LastTimestamp:  metav1.Now(),
+	}
+}
+
+func TestEventTimelines(t *testing.T) {
+	tests := []struct {
+		name   string
+		events func(c *controller)
+		want   map[string][]string
+	}{
+		{
+			name: "add",
+			events: func(c *controller) {
+				c.OnAdd(newCachedEvent("default", "a", "mqtt", "pulled"))
+				c.OnAdd(newCachedEvent("default", "b", "mqtt", "started"))
+				c.OnAdd(newCachedEvent("default", "c", "edge", "pulled"))
+			},
+			want: map[string][]string{"mqtt": {"pulled", "started"}, "edge": {"pulled"}},
+		},
+		{
+			name: "update replaces the event with the same name",
+			events: func(c *controller) {
+				c.OnAdd(newCachedEvent("default", "a", "mqtt", "pulled"))
+				c.OnAdd(newCachedEvent("default", "b", "mqtt", "started"))
+				c.OnUpdate(newCachedEvent("default", "a", "mqtt", "pulled"), newCachedEvent("default", "a", "mqtt", "pulled again"))
+			},
+			want: map[string][]string{"mqtt": {"pulled again", "started"}},
+		},
+		{
+			name: "delete removes the event",
+			events: func(c *controller) {
+				c.OnAdd(newCachedEvent("default", "a", "mqtt", "pulled"))
+				c.OnAdd(newCachedEvent("default", "b", "mqtt", "started"))
+				c.OnDelete(newCachedEvent("default", "a", "mqtt", "pulled"))
+			},
+			want: map[string][]string{"mqtt": {"started"}},
+		},
+		{
+			name: "delete tombstone",
+			events: func(c *controller) {
+				c.OnAdd(newCachedEvent("default", "a", "mqtt", "pulled"))
+				c.OnDelete(cache.DeletedFinalStateUnknown{Key: "default/a", Obj: newCachedEvent("default", "a", "mqtt", "pulled")})
+			},
+			want: map[string][]string{},
+		},
+		{
+			name: "delete unknown event",
+			events: func(c *controller) {
+				c.OnAdd(newCachedEvent("default", "a", "mqtt", "pulled"))
+				c.OnDelete(newCachedEvent("default", "b", "mqtt", "started"))
+				c.OnDelete(newCachedEvent("default", "a", "edge", "pulled"))
+			},
+			want: map[string][]string{"mqtt": {"pulled"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEventController(t)
+			tt.events(c)
+			got := map[string][]string{}
+			c.cachesMap.Range(func(key, value interface{}) bool {
+				var messages []string
+				for _, event := range value.(*eventTimeline).ordered() {
+					messages = append(messages, event.Message)
+				}
+				got[key.(string)] = messages
+				return true
+			})
+			want := map[string][]string{}
+			for pod, messages := range tt.want {
+				want[c.eventKey("default", "Pod", pod)] = messages
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("timelines = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workload
+
+import (
+	"k8s.io/client-go/tools/cache"
+	"sync"
+)
+
+// fanout is the single handler of an informer, it sends the changes of the informer to its subscriptions.
+// The changes are sent in order from the goroutine of the informer handler, a subscription of BlockPolicy
+// with a full buffer holds up the changes of the other subscriptions of the informer until it receives them.
+type fanout struct {
+	informers *Informer
+	informer  cache.SharedIndexInformer
+	// sending is held while a change is sent, so the subscriptions receive the changes in order.
+	sending sync.Mutex
+	// mu guards subscribers and backlogs.
+	mu sync.Mutex
+	// subscribers is the subscriptions of the informer, Unsubscribe removes them.
+	subscribers map[*subscription]bool
+	// backlogs is the cached objects to send as Added to the joining subscriptions before the changes.
+	backlogs map[*subscription][]interface{}
+}
+
+// newFanout return the fanout of the informer of informers joined by the subscription, its handler is added
+// to the informer which then notifies the cached objects as added.
+func newFanout(informers *Informer, informer cache.SharedIndexInformer, s *subscription) *fanout {
+	f := &fanout{informers: informers, informer: informer, subscribers: map[*subscription]bool{s: true},
+		backlogs: map[*subscription][]interface{}{}}
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			f.handle(nil, obj)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			f.handle(oldObj, newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			f.handle(obj, nil)
+		},
+	})
+	return f
+}
+
+// handle send the change from old to new to the subscriptions, after their backlog.
+func (f *fanout) handle(oldObj, newObj interface{}) {
+	f.sending.Lock()
+	defer f.sending.Unlock()
+	f.mu.Lock()
+	subscribers := make([]*subscription, 0, len(f.subscribers))
+	for s := range f.subscribers {
+		subscribers = append(subscribers, s)
+	}
+	f.mu.Unlock()
+	for _, s := range subscribers {
+		f.flush(s)
+		s.notify(f.informers, oldObj, newObj)
+	}
+}
+
+// join add the subscription, the objects cached by now are its backlog sent as Added before the changes.
+// The changes stored but not yet notified to the handler may be received twice.
+func (f *fanout) join(s *subscription) {
+	f.mu.Lock()
+	f.backlogs[s] = f.informer.GetStore().List()
+	f.subscribers[s] = true
+	f.mu.Unlock()
+	// the subscriber only receives the backlog once Subscribe returned.
+	go func() {
+		f.sending.Lock()
+		defer f.sending.Unlock()
+		f.flush(s)
+	}()
+}
+
+// flush send the backlog of the subscription, must hold sending.
+func (f *fanout) flush(s *subscription) {
+	f.mu.Lock()
+	backlog := f.backlogs[s]
+	delete(f.backlogs, s)
+	f.mu.Unlock()
+	for _, obj := range backlog {
+		s.notify(f.informers, nil, obj)
+	}
+}
+
+// leave remove the subscription, the change being sent to it may still be sent.
+func (f *fanout) leave(s *subscription) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.subscribers, s)
+	delete(f.backlogs, s)
+}
+
+// wait return once the change being sent is sent.
+func (f *fanout) wait() {
+	f.sending.Lock()
+	defer f.sending.Unlock()
+}
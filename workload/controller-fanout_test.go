@@ -0,0 +1,85 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workload
+
+import (
+	"context"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+	"time"
+	"x6t.io/ggp"
+)
+
+// subscribers return the number of fanouts of the pod informer and of subscriptions of its fanout.
+func subscribers(c *controller) (fanouts, n int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	f, ok := c.informers.fanouts[c.informers.Get(Pod)]
+	if !ok {
+		return len(c.informers.fanouts), 0
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(c.informers.fanouts), len(f.subscribers)
+}
+
+func TestFanout(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "edge", Name: "mqtt-0"}}
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	c := NewController(fake.NewSimpleClientset(pod), stopCh, WithResources(Pod)).(*controller)
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	subs := make([]ggp.Subscription, 0, 3)
+	for i := 0; i < 3; i++ {
+		sub, err := c.Subscribe("Pod", "", ggp.Everything())
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, sub)
+		// every subscription receives the cached pod, including those joining the fanout later.
+		select {
+		case change := <-sub.Changes():
+			if change.Type != ggp.Added || change.New.(*corev1.Pod).Name != "mqtt-0" {
+				t.Errorf("subscription %d first change = %+v, want mqtt-0 added", i, change)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("subscription %d received nothing", i)
+		}
+	}
+	if fanouts, n := subscribers(c); fanouts != 1 || n != 3 {
+		t.Errorf("subscribers = %d fanouts of %d subscriptions, want 1 fanout of 3", fanouts, n)
+	}
+
+	// the subscriptions leave the fanout of the replaced informers.
+	if err := c.Restart(nil); err != nil {
+		t.Fatal(err)
+	}
+	if fanouts, n := subscribers(c); fanouts != 1 || n != 3 {
+		t.Errorf("subscribers after Restart = %d fanouts of %d subscriptions, want 1 fanout of 3", fanouts, n)
+	}
+	for _, sub := range subs {
+		sub.Unsubscribe()
+	}
+	if fanouts, n := subscribers(c); fanouts != 1 || n != 0 {
+		t.Errorf("subscribers after Unsubscribe = %d fanouts of %d subscriptions, want 1 fanout of none", fanouts, n)
+	}
+}
@@ -17,57 +17,420 @@ limitations under the License.
 package workload
 
 import (
-	"errors"
+	networking "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/client-go/tools/cache"
 	"reflect"
+	"x6t.io/ggp"
 )
 
-// loadPodPrefix distinguish prefixes are k 8 s resources of pods.
-func (c *controller) loadPodPrefix(namespace string) (interface{}, bool) {
-	return c.cachesMap.Load(PodSpacePrefix(namespace))
+// indexer return the synced store of the kind, the namespace must be watched.
+func (c *controller) indexer(kind PrefixType, namespace string) (cache.Indexer, error) {
+	informer := c.getInformers().Get(kind)
+	if informer == nil {
+		return nil, &QueryError{Kind: kind.String(), Namespace: namespace, Err: ErrKindDisabled}
+	}
+	if !informer.HasSynced() {
+		return nil, &QueryError{Kind: kind.String(), Namespace: namespace, Err: ErrNotSynced}
+	}
+	if !c.namespaceWatched(namespace) {
+		return nil, &QueryError{Kind: kind.String(), Namespace: namespace, Err: ErrNamespaceNotWatched}
+	}
+	return informer.GetIndexer(), nil
 }
 
-// GetPod return get the specified pod resource based on the namespace and pod name.
-func (c *controller) GetPod(namespace, name string) *corev1.Pod {
-	if list, ok := c.loadPodPrefix(namespace); ok {
-		for _, pod := range list.([]*corev1.Pod) {
-			if pod.Name == name {
-				return pod
-			}
+// namespaceWatched return false if namespace is outside WithNamespace or WithNamespaceSelector.
+func (c *controller) namespaceWatched(namespace string) bool {
+	if namespace == corev1.NamespaceAll {
+		return true
+	}
+	if c.options.namespace != corev1.NamespaceAll && namespace != c.options.namespace {
+		return false
+	}
+	return c.namespaceInScope(namespace)
+}
+
+// query return the objects of the kind under this namespace matching the selector.
+func (c *controller) query(kind PrefixType, namespace string, selector ggp.Selector) ([]interface{}, error) {
+	indexer, err := c.indexer(kind, namespace)
+	if err != nil {
+		return nil, err
+	}
+	items, err := selectFrom(indexer, namespace, selector)
+	if err != nil {
+		return nil, err
+	}
+	return c.typed(kind, c.filterScope(items)), nil
+}
+
+// get return the object of the kind based on the namespace and name.
+func (c *controller) get(kind PrefixType, namespace, name string) (interface{}, error) {
+	indexer, err := c.indexer(kind, namespace)
+	if err != nil {
+		return nil, err
+	}
+	item, exists, err := indexer.GetByKey(namespaceKey(namespace, name))
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, &QueryError{Kind: kind.String(), Namespace: namespace, Name: name, Err: ErrNotFound}
+	}
+	return c.typed(kind, []interface{}{item})[0], nil
+}
+
+// queryIndex return the objects of the kind whose index contains the value.
+func (c *controller) queryIndex(kind PrefixType, index, value string) ([]interface{}, error) {
+	indexer, err := c.indexer(kind, corev1.NamespaceAll)
+	if err != nil {
+		return nil, err
+	}
+	items, err := indexer.ByIndex(index, value)
+	if err != nil {
+		return nil, err
+	}
+	return c.typed(kind, c.filterScope(items)), nil
+}
+
+// typed return the items of the metadata only kind as typed objects holding the metadata, see fromMetadata.
+func (c *controller) typed(kind PrefixType, items []interface{}) []interface{} {
+	informers := c.getInformers()
+	if !informers.MetadataOnly(kind) {
+		return items
+	}
+	// the stores hold the version neutral view whichever version is informed, see controller-convert.go.
+	gvk := preferredResource(kind).gvr.GroupVersion().WithKind(kind.String())
+	ret := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		ret = append(ret, fromMetadata(gvk, item))
+	}
+	return ret
+}
+
+// filterScope drop the items whose namespace is not selected by the namespace selector.
+func (c *controller) filterScope(items []interface{}) []interface{} {
+	if c.options.namespaceSelector == nil {
+		return items
+	}
+	ret := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		if c.inScope(item) {
+			ret = append(ret, item)
 		}
 	}
+	return ret
+}
+
+// getInto set ret, a pointer to the typed pointer of the kind such as **corev1.Pod, to the object of the kind
+// based on the namespace and name.
+func (c *controller) getInto(kind PrefixType, namespace, name string, ret interface{}) error {
+	item, err := c.get(kind, namespace, name)
+	if err != nil {
+		return err
+	}
+	v, target := reflect.ValueOf(item), reflect.ValueOf(ret).Elem()
+	if v.Type() != target.Type() {
+		return &QueryError{Kind: kind.String(), Namespace: namespace, Name: name, Err: ErrNotFound}
+	}
+	target.Set(v)
 	return nil
 }
 
-// GetPodByNameSpace return get all pods under this namespace.
+// listInto set ret, a pointer to the typed slice of the kind such as *[]*corev1.Pod, to the objects of the kind
+// under this namespace matching the selector.
+func (c *controller) listInto(kind PrefixType, namespace string, selector ggp.Selector, ret interface{}) error {
+	items, err := c.query(kind, namespace, selector)
+	if err != nil {
+		return err
+	}
+	target := reflect.ValueOf(ret).Elem()
+	list := reflect.MakeSlice(target.Type(), 0, len(items))
+	for _, item := range items {
+		if v := reflect.ValueOf(item); v.Type() == target.Type().Elem() {
+			list = reflect.Append(list, v)
+		}
+	}
+	target.Set(list)
+	return nil
+}
+
+// toPods convert the items of the pod store.
+func toPods(items []interface{}) []*corev1.Pod {
+	ret := make([]*corev1.Pod, 0, len(items))
+	for _, item := range items {
+		if pod, ok := item.(*corev1.Pod); ok {
+			ret = append(ret, pod)
+		}
+	}
+	return ret
+}
+
+// GetPod return get the specified pod resource based on the namespace and pod name.
+func (c *controller) GetPod(namespace, name string) (ret *corev1.Pod, err error) {
+	err = c.getInto(Pod, namespace, name, &ret)
+	return ret, err
+}
+
+// GetPodByNameSpace return get all pods under this namespace, ErrNotFound if there is none.
 func (c *controller) GetPodByNameSpace(namespace string) ([]*corev1.Pod, error) {
-	if list, ok := c.loadPodPrefix(namespace); ok {
-		return list.([]*corev1.Pod), nil
+	items, err := c.query(Pod, namespace, ggp.Everything())
+	if err != nil {
+		return nil, err
 	}
-	return nil, errors.New("pod not found")
+	if len(items) == 0 {
+		return nil, &QueryError{Kind: Pod.String(), Namespace: namespace, Err: ErrNotFound}
+	}
+	return toPods(items), nil
 }
 
-// GetPodByLabel return get exact matching pods based on namespace and label.
+// GetPodByLabel return get the pods under this namespace whose labels contain every pair of labels.
 func (c *controller) GetPodByLabel(namespace string, labels map[string]string) ([]*corev1.Pod, error) {
-	ret := make([]*corev1.Pod, 0)
-	if list, ok := c.loadPodPrefix(namespace); ok {
-		for _, pod := range list.([]*corev1.Pod) {
-			for _, label := range labels {
-				if reflect.DeepEqual(pod.Labels, label) {
-					ret = append(ret, pod)
-				}
-			}
-		}
-		return ret, nil
+	return c.ListPods(namespace, ggp.SelectorFromSet(labels))
+}
+
+// GetPodBySelector return get the pods under this namespace matching the label and field selector.
+func (c *controller) GetPodBySelector(namespace string, selector ggp.Selector) ([]*corev1.Pod, error) {
+	return c.ListPods(namespace, selector)
+}
+
+// GetPodByNode return get all pods scheduled to the node.
+func (c *controller) GetPodByNode(nodeName string) ([]*corev1.Pod, error) {
+	items, err := c.queryIndex(Pod, NodeNameIndex, nodeName)
+	if err != nil {
+		return nil, err
+	}
+	return toPods(items), nil
+}
+
+// GetPodByOwner return get all pods owned by the object with the uid, such as a ReplicaSet.
+func (c *controller) GetPodByOwner(uid string) ([]*corev1.Pod, error) {
+	items, err := c.queryIndex(Pod, OwnerUIDIndex, uid)
+	if err != nil {
+		return nil, err
 	}
-	return nil, errors.New("pod not found")
+	return toPods(items), nil
 }
 
-// GetPodEventMessage return used to save events, only the latest one is saved. make sure it's unique.
-func (c *controller) GetPodEventMessage(namespace, kind, name string) string {
-	key := PodEventMessagePrefix(name, kind, name)
-	if v, ok := c.cachesMap.Load(key); ok {
-		return v.(*corev1.Event).Message
+// List return the objects of the kind, such as Pod, under this namespace matching the selector.
+func (c *controller) List(kind, namespace string, selector ggp.Selector) ([]interface{}, error) {
+	t, ok := ParseKind(kind)
+	if !ok {
+		return nil, &QueryError{Kind: kind, Namespace: namespace, Err: ErrKindDisabled}
 	}
-	return ""
+	return c.query(t, namespace, selector)
+}
+
+// GetNamespace return get the specified Namespace based on the name.
+func (c *controller) GetNamespace(name string) (ret *corev1.Namespace, err error) {
+	err = c.getInto(NameSpace, "", name, &ret)
+	return ret, err
+}
+
+// ListNamespaces return get the Namespaces matching the selector.
+func (c *controller) ListNamespaces(selector ggp.Selector) (ret []*corev1.Namespace, err error) {
+	err = c.listInto(NameSpace, "", selector, &ret)
+	return ret, err
+}
+
+// GetNode return get the specified Node based on the name.
+func (c *controller) GetNode(name string) (ret *corev1.Node, err error) {
+	err = c.getInto(Node, "", name, &ret)
+	return ret, err
+}
+
+// ListNodes return get the Nodes matching the selector.
+func (c *controller) ListNodes(selector ggp.Selector) (ret []*corev1.Node, err error) {
+	err = c.listInto(Node, "", selector, &ret)
+	return ret, err
+}
+
+// GetStorageClass return get the specified StorageClass based on the name.
+func (c *controller) GetStorageClass(name string) (ret *storagev1.StorageClass, err error) {
+	err = c.getInto(StorageClass, "", name, &ret)
+	return ret, err
+}
+
+// ListStorageClasses return get the StorageClasses matching the selector.
+func (c *controller) ListStorageClasses(selector ggp.Selector) (ret []*storagev1.StorageClass, err error) {
+	err = c.listInto(StorageClass, "", selector, &ret)
+	return ret, err
+}
+
+// ListPods return get the Pods under this namespace matching the selector, all namespaces if empty.
+func (c *controller) ListPods(namespace string, selector ggp.Selector) (ret []*corev1.Pod, err error) {
+	err = c.listInto(Pod, namespace, selector, &ret)
+	return ret, err
+}
+
+// GetService return get the specified Service based on the namespace and name.
+func (c *controller) GetService(namespace, name string) (ret *corev1.Service, err error) {
+	err = c.getInto(Service, namespace, name, &ret)
+	return ret, err
+}
+
+// ListServices return get the Services under this namespace matching the selector, all namespaces if empty.
+func (c *controller) ListServices(namespace string, selector ggp.Selector) (ret []*corev1.Service, err error) {
+	err = c.listInto(Service, namespace, selector, &ret)
+	return ret, err
+}
+
+// GetEndpoints return get the specified Endpoints based on the namespace and name.
+func (c *controller) GetEndpoints(namespace, name string) (ret *corev1.Endpoints, err error) {
+	err = c.getInto(Endpoints, namespace, name, &ret)
+	return ret, err
+}
+
+// ListEndpoints return get the Endpoints under this namespace matching the selector, all namespaces if empty.
+func (c *controller) ListEndpoints(namespace string, selector ggp.Selector) (ret []*corev1.Endpoints, err error) {
+	err = c.listInto(Endpoints, namespace, selector, &ret)
+	return ret, err
+}
+
+// GetSecret return get the specified Secret based on the namespace and name.
+func (c *controller) GetSecret(namespace, name string) (ret *corev1.Secret, err error) {
+	err = c.getInto(Secret, namespace, name, &ret)
+	return ret, err
+}
+
+// ListSecrets return get the Secrets under this namespace matching the selector, all namespaces if empty.
+func (c *controller) ListSecrets(namespace string, selector ggp.Selector) (ret []*corev1.Secret, err error) {
+	err = c.listInto(Secret, namespace, selector, &ret)
+	return ret, err
+}
+
+// GetConfigMap return get the specified ConfigMap based on the namespace and name.
+func (c *controller) GetConfigMap(namespace, name string) (ret *corev1.ConfigMap, err error) {
+	err = c.getInto(ConfigMap, namespace, name, &ret)
+	return ret, err
+}
+
+// ListConfigMaps return get the ConfigMaps under this namespace matching the selector, all namespaces if empty.
+func (c *controller) ListConfigMaps(namespace string, selector ggp.Selector) (ret []*corev1.ConfigMap, err error) {
+	err = c.listInto(ConfigMap, namespace, selector, &ret)
+	return ret, err
+}
+
+// GetPersistentVolumeClaim return get the specified PersistentVolumeClaim based on the namespace and name.
+func (c *controller) GetPersistentVolumeClaim(namespace, name string) (ret *corev1.PersistentVolumeClaim, err error) {
+	err = c.getInto(PersistentVolumeClaim, namespace, name, &ret)
+	return ret, err
+}
+
+// ListPersistentVolumeClaims return get the PersistentVolumeClaims under this namespace matching the selector, all namespaces if empty.
+func (c *controller) ListPersistentVolumeClaims(namespace string, selector ggp.Selector) (ret []*corev1.PersistentVolumeClaim, err error) {
+	err = c.listInto(PersistentVolumeClaim, namespace, selector, &ret)
+	return ret, err
+}
+
+// GetEvent return get the specified Event based on the namespace and name.
+func (c *controller) GetEvent(namespace, name string) (ret *corev1.Event, err error) {
+	err = c.getInto(Event, namespace, name, &ret)
+	return ret, err
+}
+
+// ListEvents return get the Events under this namespace matching the selector, all namespaces if empty.
+func (c *controller) ListEvents(namespace string, selector ggp.Selector) (ret []*corev1.Event, err error) {
+	err = c.listInto(Event, namespace, selector, &ret)
+	return ret, err
+}
+
+// GetDeployment return get the specified Deployment based on the namespace and name.
+func (c *controller) GetDeployment(namespace, name string) (ret *appsv1.Deployment, err error) {
+	err = c.getInto(Deployment, namespace, name, &ret)
+	return ret, err
+}
+
+// ListDeployments return get the Deployments under this namespace matching the selector, all namespaces if empty.
+func (c *controller) ListDeployments(namespace string, selector ggp.Selector) (ret []*appsv1.Deployment, err error) {
+	err = c.listInto(Deployment, namespace, selector, &ret)
+	return ret, err
+}
+
+// GetStatefulSet return get the specified StatefulSet based on the namespace and name.
+func (c *controller) GetStatefulSet(namespace, name string) (ret *appsv1.StatefulSet, err error) {
+	err = c.getInto(StatefulSet, namespace, name, &ret)
+	return ret, err
+}
+
+// ListStatefulSets return get the StatefulSets under this namespace matching the selector, all namespaces if empty.
+func (c *controller) ListStatefulSets(namespace string, selector ggp.Selector) (ret []*appsv1.StatefulSet, err error) {
+	err = c.listInto(StatefulSet, namespace, selector, &ret)
+	return ret, err
+}
+
+// GetReplicaSet return get the specified ReplicaSet based on the namespace and name.
+func (c *controller) GetReplicaSet(namespace, name string) (ret *appsv1.ReplicaSet, err error) {
+	err = c.getInto(ReplicaSet, namespace, name, &ret)
+	return ret, err
+}
+
+// ListReplicaSets return get the ReplicaSets under this namespace matching the selector, all namespaces if empty.
+func (c *controller) ListReplicaSets(namespace string, selector ggp.Selector) (ret []*appsv1.ReplicaSet, err error) {
+	err = c.listInto(ReplicaSet, namespace, selector, &ret)
+	return ret, err
+}
+
+// GetIngress return get the specified Ingress based on the namespace and name, see controller-convert.go.
+func (c *controller) GetIngress(namespace, name string) (ret *networkingv1.Ingress, err error) {
+	err = c.getInto(Ingress, namespace, name, &ret)
+	return ret, err
+}
+
+// ListIngresses return get the Ingresses under this namespace matching the selector, all namespaces if empty.
+func (c *controller) ListIngresses(namespace string, selector ggp.Selector) (ret []*networkingv1.Ingress, err error) {
+	err = c.listInto(Ingress, namespace, selector, &ret)
+	return ret, err
+}
+
+// GetHorizontalPodAutoscaler return get the specified HorizontalPodAutoscaler based on the namespace and name,
+// see controller-convert.go.
+func (c *controller) GetHorizontalPodAutoscaler(namespace, name string) (ret *autoscalingv2.HorizontalPodAutoscaler, err error) {
+	err = c.getInto(HorizontalPodAutoscaler, namespace, name, &ret)
+	return ret, err
+}
+
+// ListHorizontalPodAutoscalers return get the HorizontalPodAutoscalers under this namespace matching the selector, all namespaces if empty.
+func (c *controller) ListHorizontalPodAutoscalers(namespace string, selector ggp.Selector) (ret []*autoscalingv2.HorizontalPodAutoscaler, err error) {
+	err = c.listInto(HorizontalPodAutoscaler, namespace, selector, &ret)
+	return ret, err
+}
+
+// GetGateway return get the specified Gateway based on the namespace and name.
+func (c *controller) GetGateway(namespace, name string) (ret *networking.Gateway, err error) {
+	err = c.getInto(Gateway, namespace, name, &ret)
+	return ret, err
+}
+
+// ListGateways return get the Gateways under this namespace matching the selector, all namespaces if empty.
+func (c *controller) ListGateways(namespace string, selector ggp.Selector) (ret []*networking.Gateway, err error) {
+	err = c.listInto(Gateway, namespace, selector, &ret)
+	return ret, err
+}
+
+// GetVirtualService return get the specified VirtualService based on the namespace and name.
+func (c *controller) GetVirtualService(namespace, name string) (ret *networking.VirtualService, err error) {
+	err = c.getInto(VirtualService, namespace, name, &ret)
+	return ret, err
+}
+
+// ListVirtualServices return get the VirtualServices under this namespace matching the selector, all namespaces if empty.
+func (c *controller) ListVirtualServices(namespace string, selector ggp.Selector) (ret []*networking.VirtualService, err error) {
+	err = c.listInto(VirtualService, namespace, selector, &ret)
+	return ret, err
+}
+
+// GetDestinationRule return get the specified DestinationRule based on the namespace and name.
+func (c *controller) GetDestinationRule(namespace, name string) (ret *networking.DestinationRule, err error) {
+	err = c.getInto(DestinationRule, namespace, name, &ret)
+	return ret, err
+}
+
+// ListDestinationRules return get the DestinationRules under this namespace matching the selector, all namespaces if empty.
+func (c *controller) ListDestinationRules(namespace string, selector ggp.Selector) (ret []*networking.DestinationRule, err error) {
+	err = c.listInto(DestinationRule, namespace, selector, &ret)
+	return ret, err
 }
@@ -0,0 +1,509 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workload_test
+
+import (
+	"context"
+	"errors"
+	networkingv1alpha3 "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"sort"
+	"testing"
+	"x6t.io/ggp"
+	"x6t.io/ggp/ggptest"
+	"x6t.io/ggp/workload"
+)
+
+func newFakePod(namespace, name, node string, labels map[string]string, owner types.UID) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels},
+		Spec:       corev1.PodSpec{NodeName: node},
+	}
+	if owner != "" {
+		pod.OwnerReferences = []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "mqtt", UID: owner}}
+	}
+	return pod
+}
+
+// newFakePodController return the started controller informing the pods of a fake clientset.
+func newFakePodController(t *testing.T, objects ...runtime.Object) ggp.ControllerService {
+	return ggptest.NewHarness(t, objects, workload.WithResources(workload.Pod)).Controller
+}
+
+func podNames(pods []*corev1.Pod) []string {
+	ret := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		ret = append(ret, pod.Namespace+"/"+pod.Name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func TestGetPodQueries(t *testing.T) {
+	c := newFakePodController(t,
+		newFakePod("default", "mqtt-0", "node-1", map[string]string{"app": "mqtt", "tier": "edge"}, "rs-1"),
+		newFakePod("default", "mqtt-1", "node-2", map[string]string{"app": "mqtt"}, "rs-1"),
+		newFakePod("default", "web-0", "node-1", map[string]string{"app": "web"}, "rs-2"),
+		newFakePod("edge", "mqtt-0", "", map[string]string{"app": "mqtt"}, ""),
+	)
+	tests := []struct {
+		name  string
+		query func() ([]*corev1.Pod, error)
+		want  []string
+	}{
+		{
+			name:  "by namespace",
+			query: func() ([]*corev1.Pod, error) { return c.GetPodByNameSpace("edge") },
+			want:  []string{"edge/mqtt-0"},
+		},
+		{
+			name:  "by label",
+			query: func() ([]*corev1.Pod, error) { return c.GetPodByLabel("default", map[string]string{"app": "mqtt"}) },
+			want:  []string{"default/mqtt-0", "default/mqtt-1"},
+		},
+		{
+			name: "by labels of all namespaces",
+			query: func() ([]*corev1.Pod, error) {
+				return c.GetPodByLabel(corev1.NamespaceAll, map[string]string{"app": "mqtt", "tier": "edge"})
+			},
+			want: []string{"default/mqtt-0"},
+		},
+		{
+			name:  "by node",
+			query: func() ([]*corev1.Pod, error) { return c.GetPodByNode("node-1") },
+			want:  []string{"default/mqtt-0", "default/web-0"},
+		},
+		{
+			name:  "by unknown node",
+			query: func() ([]*corev1.Pod, error) { return c.GetPodByNode("node-3") },
+			want:  []string{},
+		},
+		{
+			name:  "by owner",
+			query: func() ([]*corev1.Pod, error) { return c.GetPodByOwner("rs-1") },
+			want:  []string{"default/mqtt-0", "default/mqtt-1"},
+		},
+		{
+			name:  "by unknown owner",
+			query: func() ([]*corev1.Pod, error) { return c.GetPodByOwner("rs-3") },
+			want:  []string{},
+		},
+		{
+			name: "by selector",
+			query: func() ([]*corev1.Pod, error) {
+				selector, err := ggp.ParseSelector("app=mqtt", "spec.nodeName=node-1")
+				if err != nil {
+					return nil, err
+				}
+				return c.GetPodBySelector("default", selector)
+			},
+			want: []string{"default/mqtt-0"},
+		},
+		{
+			name: "by selector of all namespaces",
+			query: func() ([]*corev1.Pod, error) {
+				return c.GetPodBySelector(corev1.NamespaceAll, ggp.SelectorFromSet(map[string]string{"app": "mqtt"}))
+			},
+			want: []string{"default/mqtt-0", "default/mqtt-1", "edge/mqtt-0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pods, err := tt.query()
+			if err != nil {
+				t.Fatalf("query error = %v", err)
+			}
+			got := podNames(pods)
+			if len(got) != len(tt.want) {
+				t.Fatalf("query = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("query = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestGetPod(t *testing.T) {
+	c := newFakePodController(t, newFakePod("default", "mqtt-0", "node-1", nil, ""))
+	if pod, err := c.GetPod("default", "mqtt-0"); err != nil || pod.Spec.NodeName != "node-1" {
+		t.Errorf("GetPod() = %v, %v, want default/mqtt-0 on node-1", pod, err)
+	}
+	if pod, err := c.GetPod("edge", "mqtt-0"); !errors.Is(err, workload.ErrNotFound) {
+		t.Errorf("GetPod() = %v, %v, want ErrNotFound", pod, err)
+	}
+	if _, err := c.GetPodByNameSpace("edge"); !errors.Is(err, workload.ErrNotFound) {
+		t.Errorf("GetPodByNameSpace() error = %v, want ErrNotFound", err)
+	}
+}
+
+// queryCase is the typed queries of one kind, wrapped so every kind runs the same checks.
+type queryCase struct {
+	kind string
+	obj  runtime.Object
+	// get and list call GetX and ListX, namespace is ignored by the cluster scoped kinds.
+	get  func(c ggp.ControllerService, namespace, name string) (interface{}, error)
+	list func(c ggp.ControllerService, namespace string, selector ggp.Selector) (int, error)
+	// lister get the object through the lister of the kind.
+	lister func(c ggp.ControllerService, namespace, name string) (interface{}, error)
+}
+
+func queryCases() []queryCase {
+	meta := metav1.ObjectMeta{Namespace: "edge", Name: "mqtt", Labels: map[string]string{"app": "mqtt"}}
+	clusterMeta := metav1.ObjectMeta{Name: "mqtt", Labels: map[string]string{"app": "mqtt"}}
+	return []queryCase{
+		{
+			kind: "Namespace", obj: &corev1.Namespace{ObjectMeta: clusterMeta},
+			get: func(c ggp.ControllerService, _, name string) (interface{}, error) { return c.GetNamespace(name) },
+			list: func(c ggp.ControllerService, _ string, s ggp.Selector) (int, error) {
+				ret, err := c.ListNamespaces(s)
+				return len(ret), err
+			},
+			lister: func(c ggp.ControllerService, _, name string) (interface{}, error) {
+				return c.NamespaceLister().Get(name)
+			},
+		},
+		{
+			kind: "Node", obj: &corev1.Node{ObjectMeta: clusterMeta},
+			get: func(c ggp.ControllerService, _, name string) (interface{}, error) { return c.GetNode(name) },
+			list: func(c ggp.ControllerService, _ string, s ggp.Selector) (int, error) {
+				ret, err := c.ListNodes(s)
+				return len(ret), err
+			},
+			lister: func(c ggp.ControllerService, _, name string) (interface{}, error) { return c.NodeLister().Get(name) },
+		},
+		{
+			kind: "StorageClass", obj: &storagev1.StorageClass{ObjectMeta: clusterMeta},
+			get: func(c ggp.ControllerService, _, name string) (interface{}, error) { return c.GetStorageClass(name) },
+			list: func(c ggp.ControllerService, _ string, s ggp.Selector) (int, error) {
+				ret, err := c.ListStorageClasses(s)
+				return len(ret), err
+			},
+			lister: func(c ggp.ControllerService, _, name string) (interface{}, error) {
+				return c.StorageClassLister().Get(name)
+			},
+		},
+		{
+			kind: "Pod", obj: &corev1.Pod{ObjectMeta: meta},
+			get: func(c ggp.ControllerService, ns, name string) (interface{}, error) { return c.GetPod(ns, name) },
+			list: func(c ggp.ControllerService, ns string, s ggp.Selector) (int, error) {
+				ret, err := c.ListPods(ns, s)
+				return len(ret), err
+			},
+			lister: func(c ggp.ControllerService, ns, name string) (interface{}, error) {
+				return c.PodLister().Pods(ns).Get(name)
+			},
+		},
+		{
+			kind: "Service", obj: &corev1.Service{ObjectMeta: meta},
+			get: func(c ggp.ControllerService, ns, name string) (interface{}, error) { return c.GetService(ns, name) },
+			list: func(c ggp.ControllerService, ns string, s ggp.Selector) (int, error) {
+				ret, err := c.ListServices(ns, s)
+				return len(ret), err
+			},
+			lister: func(c ggp.ControllerService, ns, name string) (interface{}, error) {
+				return c.ServiceLister().Services(ns).Get(name)
+			},
+		},
+		{
+			kind: "Endpoints", obj: &corev1.Endpoints{ObjectMeta: meta},
+			get: func(c ggp.ControllerService, ns, name string) (interface{}, error) { return c.GetEndpoints(ns, name) },
+			list: func(c ggp.ControllerService, ns string, s ggp.Selector) (int, error) {
+				ret, err := c.ListEndpoints(ns, s)
+				return len(ret), err
+			},
+			lister: func(c ggp.ControllerService, ns, name string) (interface{}, error) {
+				return c.EndpointsLister().Endpoints(ns).Get(name)
+			},
+		},
+		{
+			kind: "Secret", obj: &corev1.Secret{ObjectMeta: meta},
+			get: func(c ggp.ControllerService, ns, name string) (interface{}, error) { return c.GetSecret(ns, name) },
+			list: func(c ggp.ControllerService, ns string, s ggp.Selector) (int, error) {
+				ret, err := c.ListSecrets(ns, s)
+				return len(ret), err
+			},
+			lister: func(c ggp.ControllerService, ns, name string) (interface{}, error) {
+				return c.SecretLister().Secrets(ns).Get(name)
+			},
+		},
+		{
+			kind: "ConfigMap", obj: &corev1.ConfigMap{ObjectMeta: meta},
+			get: func(c ggp.ControllerService, ns, name string) (interface{}, error) { return c.GetConfigMap(ns, name) },
+			list: func(c ggp.ControllerService, ns string, s ggp.Selector) (int, error) {
+				ret, err := c.ListConfigMaps(ns, s)
+				return len(ret), err
+			},
+			lister: func(c ggp.ControllerService, ns, name string) (interface{}, error) {
+				return c.ConfigMapLister().ConfigMaps(ns).Get(name)
+			},
+		},
+		{
+			kind: "PersistentVolumeClaim", obj: &corev1.PersistentVolumeClaim{ObjectMeta: meta},
+			get: func(c ggp.ControllerService, ns, name string) (interface{}, error) {
+				return c.GetPersistentVolumeClaim(ns, name)
+			},
+			list: func(c ggp.ControllerService, ns string, s ggp.Selector) (int, error) {
+				ret, err := c.ListPersistentVolumeClaims(ns, s)
+				return len(ret), err
+			},
+			lister: func(c ggp.ControllerService, ns, name string) (interface{}, error) {
+				return c.PersistentVolumeClaimLister().PersistentVolumeClaims(ns).Get(name)
+			},
+		},
+		{
+			kind: "Event", obj: &corev1.Event{ObjectMeta: meta},
+			get: func(c ggp.ControllerService, ns, name string) (interface{}, error) { return c.GetEvent(ns, name) },
+			list: func(c ggp.ControllerService, ns string, s ggp.Selector) (int, error) {
+				ret, err := c.ListEvents(ns, s)
+				return len(ret), err
+			},
+			lister: func(c ggp.ControllerService, ns, name string) (interface{}, error) {
+				return c.EventLister().Events(ns).Get(name)
+			},
+		},
+		{
+			kind: "Deployment", obj: &appsv1.Deployment{ObjectMeta: meta},
+			get: func(c ggp.ControllerService, ns, name string) (interface{}, error) { return c.GetDeployment(ns, name) },
+			list: func(c ggp.ControllerService, ns string, s ggp.Selector) (int, error) {
+				ret, err := c.ListDeployments(ns, s)
+				return len(ret), err
+			},
+			lister: func(c ggp.ControllerService, ns, name string) (interface{}, error) {
+				return c.DeploymentLister().Deployments(ns).Get(name)
+			},
+		},
+		{
+			kind: "StatefulSet", obj: &appsv1.StatefulSet{ObjectMeta: meta},
+			get: func(c ggp.ControllerService, ns, name string) (interface{}, error) { return c.GetStatefulSet(ns, name) },
+			list: func(c ggp.ControllerService, ns string, s ggp.Selector) (int, error) {
+				ret, err := c.ListStatefulSets(ns, s)
+				return len(ret), err
+			},
+			lister: func(c ggp.ControllerService, ns, name string) (interface{}, error) {
+				return c.StatefulSetLister().StatefulSets(ns).Get(name)
+			},
+		},
+		{
+			kind: "ReplicaSet", obj: &appsv1.ReplicaSet{ObjectMeta: meta},
+			get: func(c ggp.ControllerService, ns, name string) (interface{}, error) { return c.GetReplicaSet(ns, name) },
+			list: func(c ggp.ControllerService, ns string, s ggp.Selector) (int, error) {
+				ret, err := c.ListReplicaSets(ns, s)
+				return len(ret), err
+			},
+			lister: func(c ggp.ControllerService, ns, name string) (interface{}, error) {
+				return c.ReplicaSetLister().ReplicaSets(ns).Get(name)
+			},
+		},
+		{
+			kind: "Ingress", obj: &networkingv1.Ingress{ObjectMeta: meta},
+			get: func(c ggp.ControllerService, ns, name string) (interface{}, error) { return c.GetIngress(ns, name) },
+			list: func(c ggp.ControllerService, ns string, s ggp.Selector) (int, error) {
+				ret, err := c.ListIngresses(ns, s)
+				return len(ret), err
+			},
+			lister: func(c ggp.ControllerService, ns, name string) (interface{}, error) {
+				return c.IngressLister().Ingresses(ns).Get(name)
+			},
+		},
+		{
+			kind: "HorizontalPodAutoscaler", obj: &autoscalingv2.HorizontalPodAutoscaler{ObjectMeta: meta},
+			get: func(c ggp.ControllerService, ns, name string) (interface{}, error) {
+				return c.GetHorizontalPodAutoscaler(ns, name)
+			},
+			list: func(c ggp.ControllerService, ns string, s ggp.Selector) (int, error) {
+				ret, err := c.ListHorizontalPodAutoscalers(ns, s)
+				return len(ret), err
+			},
+			lister: func(c ggp.ControllerService, ns, name string) (interface{}, error) {
+				return c.HorizontalPodAutoscalerLister().HorizontalPodAutoscalers(ns).Get(name)
+			},
+		},
+		{
+			kind: "Gateway", obj: &networkingv1alpha3.Gateway{ObjectMeta: meta},
+			get: func(c ggp.ControllerService, ns, name string) (interface{}, error) { return c.GetGateway(ns, name) },
+			list: func(c ggp.ControllerService, ns string, s ggp.Selector) (int, error) {
+				ret, err := c.ListGateways(ns, s)
+				return len(ret), err
+			},
+			lister: func(c ggp.ControllerService, ns, name string) (interface{}, error) {
+				return c.GatewayLister().Gateways(ns).Get(name)
+			},
+		},
+		{
+			kind: "VirtualService", obj: &networkingv1alpha3.VirtualService{ObjectMeta: meta},
+			get: func(c ggp.ControllerService, ns, name string) (interface{}, error) {
+				return c.GetVirtualService(ns, name)
+			},
+			list: func(c ggp.ControllerService, ns string, s ggp.Selector) (int, error) {
+				ret, err := c.ListVirtualServices(ns, s)
+				return len(ret), err
+			},
+			lister: func(c ggp.ControllerService, ns, name string) (interface{}, error) {
+				return c.VirtualServiceLister().VirtualServices(ns).Get(name)
+			},
+		},
+		{
+			kind: "DestinationRule", obj: &networkingv1alpha3.DestinationRule{ObjectMeta: meta},
+			get: func(c ggp.ControllerService, ns, name string) (interface{}, error) {
+				return c.GetDestinationRule(ns, name)
+			},
+			list: func(c ggp.ControllerService, ns string, s ggp.Selector) (int, error) {
+				ret, err := c.ListDestinationRules(ns, s)
+				return len(ret), err
+			},
+			lister: func(c ggp.ControllerService, ns, name string) (interface{}, error) {
+				return c.DestinationRuleLister().DestinationRules(ns).Get(name)
+			},
+		},
+	}
+}
+
+func TestTypedQueries(t *testing.T) {
+	testTypedQueries(t)
+}
+
+// TestTransformedQueries the transforming informers list and watch every kind through the generic ListWatch.
+func TestTransformedQueries(t *testing.T) {
+	testTypedQueries(t, workload.WithTransform(workload.DropManagedFields))
+}
+
+func testTypedQueries(t *testing.T, opts ...workload.Option) {
+	cases := queryCases()
+	objects := make([]runtime.Object, 0, len(cases))
+	for _, tt := range cases {
+		objects = append(objects, tt.obj)
+	}
+	h := ggptest.NewHarness(t, objects, opts...)
+	mqtt := ggp.SelectorFromSet(map[string]string{"app": "mqtt"})
+	broker := ggp.Selector{Label: labels.SelectorFromSet(labels.Set{"app": "broker"})}
+
+	for _, tt := range cases {
+		t.Run(tt.kind, func(t *testing.T) {
+			got, err := tt.get(h.Controller, "edge", "mqtt")
+			if err != nil {
+				t.Fatalf("Get%s() error = %v", tt.kind, err)
+			}
+			if o, err := meta.Accessor(got); err != nil || o.GetName() != "mqtt" {
+				t.Errorf("Get%s() = %v, want mqtt", tt.kind, got)
+			}
+			if _, err := tt.get(h.Controller, "edge", "broker"); !errors.Is(err, workload.ErrNotFound) {
+				t.Errorf("Get%s(broker) error = %v, want ErrNotFound", tt.kind, err)
+			}
+			for _, s := range []struct {
+				selector ggp.Selector
+				want     int
+			}{{ggp.Everything(), 1}, {mqtt, 1}, {broker, 0}} {
+				if n, err := tt.list(h.Controller, "edge", s.selector); err != nil || n != s.want {
+					t.Errorf("List %s matching %q = %d, %v, want %d", tt.kind, s.selector.LabelSelector(), n, err, s.want)
+				}
+			}
+			if tt.kind == "Pod" {
+				if pods, err := h.Controller.GetPodBySelector("edge", mqtt); err != nil || len(pods) != 1 {
+					t.Errorf("GetPodBySelector() = %d pods, %v, want 1", len(pods), err)
+				}
+			}
+			if tt.lister == nil {
+				return
+			}
+			if got, err := tt.lister(h.Controller, "edge", "mqtt"); err != nil || got == nil {
+				t.Errorf("%sLister() Get() = %v, %v", tt.kind, got, err)
+			}
+		})
+	}
+}
+
+func TestDisabledListers(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	c := workload.NewController(fake.NewSimpleClientset(), stopCh, workload.WithResources(workload.Pod))
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range queryCases() {
+		if tt.lister == nil || tt.kind == "Pod" {
+			continue
+		}
+		if _, err := tt.lister(c, "edge", "mqtt"); !errors.Is(err, workload.ErrKindDisabled) {
+			t.Errorf("%sLister() Get() error = %v, want ErrKindDisabled", tt.kind, err)
+		}
+	}
+	if pods, err := c.PodLister().List(labels.Everything()); err != nil || len(pods) != 0 {
+		t.Errorf("PodLister() List() = %v, %v, want no pod", pods, err)
+	}
+	if gateways, err := c.GatewayLister().List(labels.Everything()); err != nil || len(gateways) != 0 {
+		t.Errorf("GatewayLister() List() = %v, %v, want nothing for a disabled kind", gateways, err)
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "edge", Name: "mqtt"}}
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	c := workload.NewController(fake.NewSimpleClientset(pod), stopCh,
+		workload.WithResources(workload.Pod), workload.WithNamespace("edge"))
+
+	// the informers do not run before Start.
+	_, err := c.GetPod("edge", "mqtt")
+	var queryErr *workload.QueryError
+	if !errors.Is(err, workload.ErrNotSynced) || !errors.As(err, &queryErr) || queryErr.Kind != "Pod" {
+		t.Errorf("GetPod() before Start error = %v, want ErrNotSynced", err)
+	}
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query func() error
+		want  error
+	}{
+		{name: "found", query: func() error { _, err := c.GetPod("edge", "mqtt"); return err }},
+		{name: "not found", query: func() error { _, err := c.GetPod("edge", "broker"); return err }, want: workload.ErrNotFound},
+		{name: "kind disabled", query: func() error { _, err := c.GetSecret("edge", "mqtt"); return err }, want: workload.ErrKindDisabled},
+		{name: "list kind disabled", query: func() error { _, err := c.ListSecrets("edge", ggp.Everything()); return err }, want: workload.ErrKindDisabled},
+		{name: "namespace not watched", query: func() error { _, err := c.GetPod("cloud", "mqtt"); return err }, want: workload.ErrNamespaceNotWatched},
+		{name: "list namespace not watched", query: func() error { _, err := c.ListPods("cloud", ggp.Everything()); return err }, want: workload.ErrNamespaceNotWatched},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.query()
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("error = %v, want none", err)
+				}
+				return
+			}
+			var queryErr *workload.QueryError
+			if !errors.Is(err, tt.want) || !errors.As(err, &queryErr) {
+				t.Errorf("error = %v, want a QueryError of %v", err, tt.want)
+			}
+		})
+	}
+}
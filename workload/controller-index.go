@@ -0,0 +1,99 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workload
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// NamespaceIndex indexes objects by namespace, registered by every informer factory.
+	NamespaceIndex = cache.NamespaceIndex
+	// LabelIndex indexes objects by label key and by "key=value" label pair.
+	LabelIndex = "label"
+	// NodeNameIndex indexes pods by spec.nodeName.
+	NodeNameIndex = "nodeName"
+	// OwnerUIDIndex indexes objects by the uid of their owner references.
+	OwnerUIDIndex = "ownerUID"
+)
+
+// DefaultIndexers return the indexers added to every informer store.
+// The namespace/name lookup does not need one, it is the key of the store itself.
+func DefaultIndexers() cache.Indexers {
+	return cache.Indexers{
+		LabelIndex:    LabelIndexFunc,
+		OwnerUIDIndex: OwnerUIDIndexFunc,
+	}
+}
+
+// LabelIndexFunc return the label keys and the "key=value" label pairs of obj.
+func LabelIndexFunc(obj interface{}) ([]string, error) {
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]string, 0, len(o.GetLabels())*2)
+	for k, v := range o.GetLabels() {
+		ret = append(ret, k, LabelIndexValue(k, v))
+	}
+	return ret, nil
+}
+
+// LabelIndexValue return the LabelIndex value of the label pair.
+func LabelIndexValue(key, value string) string {
+	return key + "=" + value
+}
+
+// OwnerUIDIndexFunc return the uid of every owner reference of obj.
+func OwnerUIDIndexFunc(obj interface{}) ([]string, error) {
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]string, 0, len(o.GetOwnerReferences()))
+	for _, ref := range o.GetOwnerReferences() {
+		ret = append(ret, string(ref.UID))
+	}
+	return ret, nil
+}
+
+// NodeNameIndexFunc return the node name a pod is scheduled to.
+func NodeNameIndexFunc(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || pod.Spec.NodeName == "" {
+		return []string{}, nil
+	}
+	return []string{pod.Spec.NodeName}, nil
+}
+
+// namespaceKey return the store key of the object, same as cache.MetaNamespaceKeyFunc.
+func namespaceKey(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+// byNamespace return all objects under namespace, all objects of the store if namespace is empty.
+func byNamespace(indexer cache.Indexer, namespace string) ([]interface{}, error) {
+	if namespace == corev1.NamespaceAll {
+		return indexer.List(), nil
+	}
+	return indexer.ByIndex(NamespaceIndex, namespace)
+}
@@ -19,71 +19,83 @@ package workload
 import (
 	istio "istio.io/client-go/pkg/listers/networking/v1alpha3"
 	appsv1 "k8s.io/client-go/listers/apps/v1"
-	autoscalingv2 "k8s.io/client-go/listers/autoscaling/v2beta2"
+	autoscalingv2 "k8s.io/client-go/listers/autoscaling/v2"
 	corev1 "k8s.io/client-go/listers/core/v1"
-	"k8s.io/client-go/listers/extensions/v1beta1"
+	networkingv1 "k8s.io/client-go/listers/networking/v1"
 	storagev1 "k8s.io/client-go/listers/storage/v1"
 )
 
-func (c *controller) IngressLister() v1beta1.IngressLister {
-	return c.listers.Ingress
+func (c *controller) NamespaceLister() corev1.NamespaceLister {
+	return c.getListers().Namespace
+}
+
+func (c *controller) IngressLister() networkingv1.IngressLister {
+	return c.getListers().Ingress
 }
 
 func (c *controller) ServiceLister() corev1.ServiceLister {
-	return c.listers.Service
+	return c.getListers().Service
 }
 
 func (c *controller) SecretLister() corev1.SecretLister {
-	return c.listers.Secret
+	return c.getListers().Secret
 }
 
 func (c *controller) StatefulSetLister() appsv1.StatefulSetLister {
-	return c.listers.StatefulSet
+	return c.getListers().StatefulSet
 }
 
 func (c *controller) DeploymentLister() appsv1.DeploymentLister {
-	return c.listers.Deployment
+	return c.getListers().Deployment
+}
+
+func (c *controller) ReplicaSetLister() appsv1.ReplicaSetLister {
+	return c.getListers().ReplicaSet
 }
 
 // 	PodLister The following two ways are the same.
 //  pods, err := c.PodLister().Pods(v1.NamespaceAll).List(labels.NewSelector())
 //  pods,err := kubectl.CoreV1().Pods(v1.NamespaceAll).List(metav1.ListOptions{
 func (c *controller) PodLister() corev1.PodLister {
-	return c.listers.Pod
+	return c.getListers().Pod
 }
 
 func (c *controller) ConfigMapLister() corev1.ConfigMapLister {
-	return c.listers.ConfigMap
+	return c.getListers().ConfigMap
 }
 
 func (c *controller) EndpointsLister() corev1.EndpointsLister {
-	return c.listers.Endpoints
+	return c.getListers().Endpoints
 }
 
 func (c *controller) NodeLister() corev1.NodeLister {
-	return c.listers.Nodes
+	return c.getListers().Nodes
 }
 
 func (c *controller) StorageClassLister() storagev1.StorageClassLister {
-	return c.listers.StorageClass
+	return c.getListers().StorageClass
 }
 
 func (c *controller) PersistentVolumeClaimLister() corev1.PersistentVolumeClaimLister {
-	return c.listers.Claims
+	return c.getListers().Claims
+}
+
+func (c *controller) EventLister() corev1.EventLister {
+	return c.getListers().Event
 }
 
 func (c *controller) HorizontalPodAutoscalerLister() autoscalingv2.HorizontalPodAutoscalerLister {
-	return c.listers.HorizontalPodAutoscaler
+	return c.getListers().HorizontalPodAutoscaler
 }
 
 func (c *controller) GatewayLister() istio.GatewayLister {
-	return c.listers.Gateways
+	return c.getListers().Gateways
 }
 
 func (c *controller) VirtualServiceLister() istio.VirtualServiceLister {
-	return c.listers.VirtualService
+	return c.getListers().VirtualService
 }
 
 func (c *controller) DestinationRuleLister() istio.DestinationRuleLister {
-	return c.listers.DestinationRule
+	return c.getListers().DestinationRule
 }
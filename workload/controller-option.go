@@ -0,0 +1,290 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workload
+
+import (
+	istio "istio.io/client-go/pkg/clientset/versioned"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/tools/cache"
+	"time"
+)
+
+// Option configures the controller created by NewController.
+type Option func(*options)
+
+type options struct {
+	// istioClient informs the istio kinds, they are disabled without it.
+	istioClient istio.Interface
+	// dynamicClient informs the resources of RegisterDynamic, they can not be registered without it.
+	dynamicClient dynamic.Interface
+	// metadataClient informs the metadata only kinds, they are informed in full without it.
+	metadataClient metadata.Interface
+	// metadataKinds is the kinds informed as metadata only.
+	metadataKinds map[PrefixType]bool
+	// kinds is the enabled kinds, nil means all kinds.
+	kinds map[PrefixType]bool
+	// namespace is the only watched namespace, empty means all namespaces.
+	namespace string
+	// namespaceSelector selects the namespaces whose objects are kept, nil means all namespaces.
+	namespaceSelector labels.Selector
+	// tweaks is the list options tweak of every kind, the tweak of all kinds is saved under allKinds.
+	tweaks map[PrefixType][]func(*metav1.ListOptions)
+	// transforms is the transforms of every kind, the transforms of all kinds are saved under allKinds.
+	transforms map[PrefixType][]cache.TransformFunc
+	// resyncs is the resync period of every kind, the resync of all kinds is saved under allKinds.
+	resyncs map[PrefixType]time.Duration
+	// syncTimeout is the longest time Start waits for the informers to sync, 0 means no limit.
+	syncTimeout time.Duration
+	// eventBufferSize is the number of events kept for one object.
+	eventBufferSize int
+	// eventTTL is the time an event is kept after its last occurrence, 0 means until it is deleted.
+	eventTTL time.Duration
+}
+
+// allKinds is the options key applied to every kind.
+const allKinds PrefixType = -1
+
+func newOptions(opts ...Option) *options {
+	o := &options{
+		tweaks:        map[PrefixType][]func(*metav1.ListOptions){},
+		transforms:    map[PrefixType][]cache.TransformFunc{},
+		metadataKinds: map[PrefixType]bool{},
+		resyncs: map[PrefixType]time.Duration{
+			allKinds:     DefaultResyncPeriod,
+			StorageClass: DefaultStorageClassResyncPeriod,
+		},
+		syncTimeout:     DefaultSyncTimeout,
+		eventBufferSize: DefaultEventBufferSize,
+		eventTTL:        DefaultEventTTL,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	// the namespace selector is resolved against the namespace cache.
+	if o.namespaceSelector != nil && o.kinds != nil {
+		o.kinds[NameSpace] = true
+	}
+	return o
+}
+
+// WithResources enable only the informers of kinds, all kinds are enabled by default.
+// Can be used several times, the kinds are merged. The istio kinds need WithIstioClient,
+// Start returns ErrNoIstioClient otherwise.
+func WithResources(kinds ...PrefixType) Option {
+	return func(o *options) {
+		if o.kinds == nil {
+			o.kinds = map[PrefixType]bool{}
+		}
+		for _, kind := range kinds {
+			o.kinds[kind] = true
+		}
+	}
+}
+
+// WithIstioClient enable the istio kinds, Gateway, VirtualService and DestinationRule.
+// Without it they are informed only if WithResources requires them, and Start returns ErrNoIstioClient.
+func WithIstioClient(client istio.Interface) Option {
+	return func(o *options) {
+		o.istioClient = client
+	}
+}
+
+// WithDynamicClient set the client of the resources registered by RegisterDynamic, such as CRDs.
+// NewManagerController sets it from the dynamic client of the ManagerClient.
+func WithDynamicClient(client dynamic.Interface) Option {
+	return func(o *options) {
+		o.dynamicClient = client
+	}
+}
+
+// WithMetadataClient set the client of the metadata only kinds, see WithMetadataOnly.
+// NewManagerController sets it from the metadata client of the ManagerClient.
+func WithMetadataClient(client metadata.Interface) Option {
+	return func(o *options) {
+		o.metadataClient = client
+	}
+}
+
+// WithMetadataOnly inform kinds as metadata only, such as Secret and ConfigMap whose data are rarely needed.
+// The objects keep their name, labels, annotations and owner references, the queries return them with
+// an empty spec, status and data, the listers of kinds are empty and GetEvents needs Event informed in full.
+// It needs WithMetadataClient, kinds are informed in full otherwise.
+func WithMetadataOnly(kinds ...PrefixType) Option {
+	return func(o *options) {
+		for _, kind := range kinds {
+			o.metadataKinds[kind] = true
+		}
+	}
+}
+
+// WithNamespace watch only the namespaced objects under namespace, cluster scoped kinds are not affected.
+func WithNamespace(namespace string) Option {
+	return func(o *options) {
+		o.namespace = namespace
+	}
+}
+
+// WithNamespaceSelector keep only the namespaced objects whose namespace labels match selector.
+// The objects are still listed cluster wide, they are filtered before reaching the handlers and the queries,
+// so moving a namespace in or out of the selector takes effect immediately.
+func WithNamespaceSelector(selector labels.Selector) Option {
+	return func(o *options) {
+		o.namespaceSelector = selector
+	}
+}
+
+// WithTweakListOptions tweak the list and watch options of kinds, of every kind if none is given.
+// Such as the label selector or the field selector sent to the k8s apiserver.
+func WithTweakListOptions(tweak func(*metav1.ListOptions), kinds ...PrefixType) Option {
+	return func(o *options) {
+		if len(kinds) == 0 {
+			kinds = []PrefixType{allKinds}
+		}
+		for _, kind := range kinds {
+			o.tweaks[kind] = append(o.tweaks[kind], tweak)
+		}
+	}
+}
+
+// WithTransform transform the objects of kinds, of every kind if none is given, before they are stored
+// in the informer caches, such as DropManagedFields, TruncateAnnotations or DropSecretData on Secret.
+// The transforms run in the order they are given and may modify the objects in place, the transformed
+// objects are the ones returned by the queries, the listers and the handlers.
+func WithTransform(transform cache.TransformFunc, kinds ...PrefixType) Option {
+	return func(o *options) {
+		if len(kinds) == 0 {
+			kinds = []PrefixType{allKinds}
+		}
+		for _, kind := range kinds {
+			o.transforms[kind] = append(o.transforms[kind], transform)
+		}
+	}
+}
+
+// WithResyncPeriod set the resync period of kinds, of every kind if none is given.
+// Default DefaultResyncPeriod, and DefaultStorageClassResyncPeriod for StorageClass.
+func WithResyncPeriod(resync time.Duration, kinds ...PrefixType) Option {
+	return func(o *options) {
+		if len(kinds) == 0 {
+			kinds = []PrefixType{allKinds}
+		}
+		for _, kind := range kinds {
+			o.resyncs[kind] = resync
+		}
+	}
+}
+
+// WithSyncTimeout set the longest time Start waits for the informers to sync, 0 means until the context is done.
+// Default DefaultSyncTimeout.
+func WithSyncTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.syncTimeout = timeout
+	}
+}
+
+// WithEventBuffer set the number of events kept for one object and how long they are kept after
+// their last occurrence, 0 ttl keeps them until they are deleted from k8s.
+// Default DefaultEventBufferSize and DefaultEventTTL.
+func WithEventBuffer(size int, ttl time.Duration) Option {
+	return func(o *options) {
+		if size > 0 {
+			o.eventBufferSize = size
+		}
+		o.eventTTL = ttl
+	}
+}
+
+// enabledKinds return the enabled kinds in a stable order.
+func (o *options) enabledKinds() []PrefixType {
+	ret := make([]PrefixType, 0, len(kinds))
+	for _, kind := range sortedKinds() {
+		switch {
+		case o.kinds != nil:
+			if o.kinds[kind] {
+				ret = append(ret, kind)
+			}
+		// the istio kinds are informed by default only with an istio client, Start fails if they are required without it.
+		case kinds[kind].istio:
+			if o.istioClient != nil {
+				ret = append(ret, kind)
+			}
+		default:
+			ret = append(ret, kind)
+		}
+	}
+	return ret
+}
+
+// metadataOnly return true if the kind is informed as metadata only.
+func (o *options) metadataOnly(kind PrefixType) bool {
+	return o.metadataClient != nil && o.metadataKinds[kind]
+}
+
+// resyncFor return the resync period of the kind.
+func (o *options) resyncFor(kind PrefixType) time.Duration {
+	if resync, ok := o.resyncs[kind]; ok {
+		return resync
+	}
+	return o.resyncs[allKinds]
+}
+
+// tweakFor return the list options tweak of the kind, of every kind only for allKinds.
+// The Namespace informer backing the namespace selector only takes its own tweaks, the tweaks of every kind
+// meant for the workloads, such as a field selector on spec.nodeName, would drop the namespaces in scope.
+func (o *options) tweakFor(kind PrefixType) func(*metav1.ListOptions) {
+	var tweaks []func(*metav1.ListOptions)
+	if kind != NameSpace || o.namespaceSelector == nil {
+		tweaks = append(tweaks, o.tweaks[allKinds]...)
+	}
+	if kind != allKinds {
+		tweaks = append(tweaks, o.tweaks[kind]...)
+	}
+	// the namespace selector narrows the label selector of the other tweaks, the requirements are ANDed.
+	if kind == NameSpace && o.namespaceSelector != nil && !o.namespaceSelector.Empty() {
+		selector := o.namespaceSelector.String()
+		tweaks = append(tweaks, func(options *metav1.ListOptions) {
+			if options.LabelSelector == "" {
+				options.LabelSelector = selector
+				return
+			}
+			options.LabelSelector += "," + selector
+		})
+	}
+	if len(tweaks) == 0 {
+		return nil
+	}
+	return func(options *metav1.ListOptions) {
+		for _, tweak := range tweaks {
+			tweak(options)
+		}
+	}
+}
+
+// transformFor return the transform of the kind, of every kind only for allKinds, nil if there is none.
+func (o *options) transformFor(kind PrefixType) cache.TransformFunc {
+	transforms := append([]cache.TransformFunc{}, o.transforms[allKinds]...)
+	if kind != allKinds {
+		transforms = append(transforms, o.transforms[kind]...)
+	}
+	if len(transforms) == 0 {
+		return nil
+	}
+	return chainTransforms(transforms)
+}
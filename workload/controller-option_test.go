@@ -0,0 +1,245 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workload
+
+import (
+	"context"
+	istiofake "istio.io/client-go/pkg/clientset/versioned/fake"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes/fake"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEnabledKinds(t *testing.T) {
+	selector := labels.SelectorFromSet(labels.Set{"edge": "true"})
+	tests := []struct {
+		name string
+		opts []Option
+		want []PrefixType
+	}{
+		{
+			name: "resources",
+			opts: []Option{WithResources(Service, Pod), WithResources(Secret)},
+			want: []PrefixType{Pod, Service, Secret},
+		},
+		{
+			name: "namespace selector",
+			opts: []Option{WithResources(Pod), WithNamespaceSelector(selector)},
+			want: []PrefixType{NameSpace, Pod},
+		},
+		{
+			name: "istio client",
+			opts: []Option{WithResources(Pod, Gateway), WithIstioClient(istiofake.NewSimpleClientset())},
+			want: []PrefixType{Pod, Gateway},
+		},
+		{
+			name: "istio kind without client",
+			opts: []Option{WithResources(Gateway)},
+			want: []PrefixType{Gateway},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newOptions(tt.opts...).enabledKinds(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("enabledKinds() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	all := newOptions().enabledKinds()
+	k8sKinds := 0
+	for _, info := range kinds {
+		if !info.istio {
+			k8sKinds++
+		}
+	}
+	if len(all) != k8sKinds {
+		t.Errorf("enabledKinds() = %v, want every k8s kind by default", all)
+	}
+	for _, kind := range all {
+		if kind == Gateway || kind == VirtualService || kind == DestinationRule {
+			t.Errorf("enabledKinds() = %v, want no istio kind by default without the istio client", all)
+		}
+	}
+}
+
+func TestResyncFor(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		kind PrefixType
+		want time.Duration
+	}{
+		{name: "default", kind: Pod, want: DefaultResyncPeriod},
+		{name: "default storage class", kind: StorageClass, want: DefaultStorageClassResyncPeriod},
+		{name: "every kind", opts: []Option{WithResyncPeriod(time.Minute)}, kind: Pod, want: time.Minute},
+		{name: "kind", opts: []Option{WithResyncPeriod(time.Minute), WithResyncPeriod(time.Hour, Pod)}, kind: Pod, want: time.Hour},
+		{name: "other kind", opts: []Option{WithResyncPeriod(time.Hour, Pod)}, kind: Service, want: DefaultResyncPeriod},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newOptions(tt.opts...).resyncFor(tt.kind); got != tt.want {
+				t.Errorf("resyncFor(%s) = %s, want %s", tt.kind, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTweakFor(t *testing.T) {
+	labelTweak := func(selector string) func(*metav1.ListOptions) {
+		return func(options *metav1.ListOptions) {
+			options.LabelSelector = selector
+		}
+	}
+	fieldTweak := func(options *metav1.ListOptions) {
+		options.FieldSelector = "spec.nodeName=node-1"
+	}
+	selector := labels.SelectorFromSet(labels.Set{"edge": "true"})
+	tests := []struct {
+		name      string
+		opts      []Option
+		kind      PrefixType
+		wantNil   bool
+		wantLabel string
+		wantField string
+	}{
+		{name: "none", kind: Pod, wantNil: true},
+		{name: "every kind", opts: []Option{WithTweakListOptions(labelTweak("app=mqtt"))}, kind: Pod, wantLabel: "app=mqtt"},
+		{
+			name:      "kind after every kind",
+			opts:      []Option{WithTweakListOptions(fieldTweak, Pod), WithTweakListOptions(labelTweak("app=mqtt"))},
+			kind:      Pod,
+			wantLabel: "app=mqtt",
+			wantField: "spec.nodeName=node-1",
+		},
+		{name: "other kind", opts: []Option{WithTweakListOptions(fieldTweak, Pod)}, kind: Service, wantNil: true},
+		{name: "namespace selector", opts: []Option{WithNamespaceSelector(selector)}, kind: NameSpace, wantLabel: "edge=true"},
+		{
+			name:      "namespace selector merged",
+			opts:      []Option{WithNamespaceSelector(selector), WithTweakListOptions(labelTweak("tier!=test"), NameSpace)},
+			kind:      NameSpace,
+			wantLabel: "tier!=test,edge=true",
+		},
+		{
+			name:      "namespace selector without the tweaks of every kind",
+			opts:      []Option{WithNamespaceSelector(selector), WithTweakListOptions(labelTweak("app=mqtt")), WithTweakListOptions(fieldTweak)},
+			kind:      NameSpace,
+			wantLabel: "edge=true",
+		},
+		{
+			name:      "namespace without selector",
+			opts:      []Option{WithTweakListOptions(labelTweak("app=mqtt"))},
+			kind:      NameSpace,
+			wantLabel: "app=mqtt",
+		},
+		{name: "namespace selector of other kinds", opts: []Option{WithNamespaceSelector(selector)}, kind: Pod, wantNil: true},
+		{name: "empty namespace selector", opts: []Option{WithNamespaceSelector(labels.Everything())}, kind: NameSpace, wantNil: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tweak := newOptions(tt.opts...).tweakFor(tt.kind)
+			if tt.wantNil {
+				if tweak != nil {
+					t.Errorf("tweakFor(%s) is not nil", tt.kind)
+				}
+				return
+			}
+			options := &metav1.ListOptions{}
+			tweak(options)
+			if options.LabelSelector != tt.wantLabel || options.FieldSelector != tt.wantField {
+				t.Errorf("tweakFor(%s) = %q %q, want %q %q", tt.kind, options.LabelSelector, options.FieldSelector, tt.wantLabel, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestNamespaceSelector(t *testing.T) {
+	namespace := func(name string, labels map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	pod := func(namespace, name string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	}
+	clientset := fake.NewSimpleClientset(
+		namespace("edge", map[string]string{"edge": "true", "tier": "prod"}),
+		namespace("edge-test", map[string]string{"edge": "true", "tier": "test"}),
+		namespace("cloud", map[string]string{"tier": "prod"}),
+		pod("edge", "mqtt-0"), pod("edge-test", "mqtt-0"), pod("cloud", "mqtt-0"),
+	)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	c := NewController(clientset, stopCh,
+		WithResources(Pod),
+		WithNamespaceSelector(labels.SelectorFromSet(labels.Set{"edge": "true"})),
+		WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = "tier!=test"
+		}, NameSpace),
+	).(*controller)
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ns := range []string{"edge", "edge-test", "cloud"} {
+		pods, err := c.GetPodByNameSpace(ns)
+		want := 0
+		if ns == "edge" {
+			want = 1
+		}
+		if len(pods) != want {
+			t.Errorf("GetPodByNameSpace(%s) = %d pods, %v, want %d", ns, len(pods), err, want)
+		}
+	}
+	if _, exists, _ := c.informers.Namespace.GetIndexer().GetByKey("edge-test"); exists {
+		t.Errorf("namespace edge-test is cached, want it dropped by the label tweak")
+	}
+}
+
+func TestNamespaceSelectorWithGlobalTweak(t *testing.T) {
+	pod := func(namespace, name, app string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: map[string]string{"app": app}}}
+	}
+	clientset := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "edge", Labels: map[string]string{"edge": "true"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "cloud"}},
+		pod("edge", "mqtt-0", "mqtt"), pod("edge", "redis-0", "redis"), pod("cloud", "mqtt-0", "mqtt"),
+	)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	// the tweak of every kind selects the workloads, the namespaces are selected by the namespace selector only.
+	c := NewController(clientset, stopCh,
+		WithResources(Pod),
+		WithNamespaceSelector(labels.SelectorFromSet(labels.Set{"edge": "true"})),
+		WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = "app=mqtt"
+		}),
+	).(*controller)
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	pods, err := c.GetPodByNameSpace("edge")
+	if err != nil || len(pods) != 1 || pods[0].Name != "mqtt-0" {
+		t.Errorf("GetPodByNameSpace(edge) = %v, %v, want mqtt-0 only", pods, err)
+	}
+	if pods, err := c.GetPodByNameSpace("cloud"); len(pods) != 0 {
+		t.Errorf("GetPodByNameSpace(cloud) = %v, %v, want no pod out of the namespace selector", pods, err)
+	}
+}
@@ -34,6 +34,10 @@ const (
 	DefaultPersistentVolumeClaimPrefix   = "ggp-persistentvolume-claim"
 	DefaultEndPointsPrefix               = "ggp-endpoints"
 	DefaultHorizontalPodAutoscalerPrefix = "ggp-horizontal-pod-autoscaler"
+	DefaultNodePrefix                    = "ggp-node"
+	DefaultGatewayPrefix                 = "ggp-gateway"
+	DefaultVirtualServicePrefix          = "ggp-virtual-service"
+	DefaultDestinationRulePrefix         = "ggp-destination-rule"
 )
 
 type PrefixType int
@@ -53,8 +57,54 @@ const (
 	PersistentVolumeClaim
 	Endpoints
 	HorizontalPodAutoscaler
+	Node
+	Gateway
+	VirtualService
+	DestinationRule
 )
 
+// kindNames is the k8s kind of every PrefixType.
+var kindNames = map[PrefixType]string{
+	NameSpace:               "Namespace",
+	Ingress:                 "Ingress",
+	Pod:                     "Pod",
+	Service:                 "Service",
+	Secret:                  "Secret",
+	StatefulSet:             "StatefulSet",
+	Event:                   "Event",
+	Deployment:              "Deployment",
+	ConfigMap:               "ConfigMap",
+	ReplicaSet:              "ReplicaSet",
+	StorageClass:            "StorageClass",
+	PersistentVolumeClaim:   "PersistentVolumeClaim",
+	Endpoints:               "Endpoints",
+	HorizontalPodAutoscaler: "HorizontalPodAutoscaler",
+	Node:                    "Node",
+	Gateway:                 "Gateway",
+	VirtualService:          "VirtualService",
+	DestinationRule:         "DestinationRule",
+}
+
+// String return the k8s kind, such as Pod.
+func (p PrefixType) String() string {
+	return kindNames[p]
+}
+
+// Resource return the k8s resource of the kind, such as pods.
+func (p PrefixType) Resource() string {
+	return preferredResource(p).gvr.Resource
+}
+
+// ParseKind return the PrefixType of the k8s kind, such as Pod.
+func ParseKind(kind string) (PrefixType, bool) {
+	for p, name := range kindNames {
+		if name == kind {
+			return p, true
+		}
+	}
+	return 0, false
+}
+
 func (p PrefixType) Strings(s ...string) string {
 	switch p {
 	case NameSpace:
@@ -83,6 +133,14 @@ func (p PrefixType) Strings(s ...string) string {
 		return filepath.Join(DefaultEndPointsPrefix, s[0])
 	case HorizontalPodAutoscaler:
 		return filepath.Join(DefaultHorizontalPodAutoscalerPrefix, s[0])
+	case Node:
+		return filepath.Join(DefaultNodePrefix, s[0])
+	case Gateway:
+		return filepath.Join(DefaultGatewayPrefix, s[0])
+	case VirtualService:
+		return filepath.Join(DefaultVirtualServicePrefix, s[0])
+	case DestinationRule:
+		return filepath.Join(DefaultDestinationRulePrefix, s[0])
 	case Event:
 		if len(s) != 3 {
 			return ""
@@ -99,19 +157,19 @@ func (c *controller) Prefix(t PrefixType, s ...string) string {
 }
 
 // NameSpacePrefix Namespace related prefix.
-// Deprecated: will be removed in the next commit.
+// Deprecated: the namespaces are no longer saved under a prefix, use GetNamespace.
 func NameSpacePrefix(name string) string {
 	return filepath.Join(DefaultNameSpacePrefix, name)
 }
 
 // PodSpacePrefix Pod related prefix.
-// Deprecated: will be removed in the next commit.
+// Deprecated: the pods are no longer saved under a prefix, use GetPodByNameSpace.
 func PodSpacePrefix(namespace string) string {
 	return filepath.Join(DefaultPodSpacePrefix, namespace)
 }
 
 // EndpointsSpacePrefix related prefix
-// Deprecated: will be removed in the next commit.
+// Deprecated: the endpoints are no longer saved under a prefix, use ListEndpoints.
 func EndpointsSpacePrefix(namespace string) string {
 	return filepath.Join(DefaultEndPointsPrefix, namespace)
 }
@@ -0,0 +1,149 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workload
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/client-go/tools/cache"
+	"strconv"
+	"x6t.io/ggp"
+)
+
+// ObjectFields return the fields of obj supported by field selectors.
+// All kinds support metadata.name and metadata.namespace, the others follow the kube-apiserver.
+func ObjectFields(obj interface{}) fields.Set {
+	set := fields.Set{}
+	if o, err := meta.Accessor(obj); err == nil {
+		set["metadata.name"] = o.GetName()
+		set["metadata.namespace"] = o.GetNamespace()
+	}
+	switch o := obj.(type) {
+	case *corev1.Pod:
+		set["spec.nodeName"] = o.Spec.NodeName
+		set["spec.restartPolicy"] = string(o.Spec.RestartPolicy)
+		set["spec.schedulerName"] = o.Spec.SchedulerName
+		set["spec.serviceAccountName"] = o.Spec.ServiceAccountName
+		set["status.phase"] = string(o.Status.Phase)
+		set["status.podIP"] = o.Status.PodIP
+		set["status.nominatedNodeName"] = o.Status.NominatedNodeName
+	case *corev1.Node:
+		set["spec.unschedulable"] = strconv.FormatBool(o.Spec.Unschedulable)
+	case *corev1.Namespace:
+		set["status.phase"] = string(o.Status.Phase)
+	case *corev1.Secret:
+		set["type"] = string(o.Type)
+	case *corev1.Service:
+		set["spec.clusterIP"] = o.Spec.ClusterIP
+		set["spec.type"] = string(o.Spec.Type)
+	case *corev1.Event:
+		set["involvedObject.kind"] = o.InvolvedObject.Kind
+		set["involvedObject.namespace"] = o.InvolvedObject.Namespace
+		set["involvedObject.name"] = o.InvolvedObject.Name
+		set["involvedObject.uid"] = string(o.InvolvedObject.UID)
+		set["involvedObject.apiVersion"] = o.InvolvedObject.APIVersion
+		set["involvedObject.resourceVersion"] = o.InvolvedObject.ResourceVersion
+		set["involvedObject.fieldPath"] = o.InvolvedObject.FieldPath
+		set["reason"] = o.Reason
+		set["source"] = o.Source.Component
+		set["type"] = o.Type
+	}
+	return set
+}
+
+// selectFrom return the objects of indexer under namespace matching the selector.
+// The candidates are narrowed with the indexes first, then every candidate is matched.
+func selectFrom(indexer cache.Indexer, namespace string, selector ggp.Selector) ([]interface{}, error) {
+	labelSelector, fieldSelector := selector.LabelSelector(), selector.FieldSelector()
+	if namespace == corev1.NamespaceAll {
+		namespace, _ = fieldSelector.RequiresExactMatch("metadata.namespace")
+	}
+	candidates, err := candidatesFrom(indexer, namespace, labelSelector, fieldSelector)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]interface{}, 0, len(candidates))
+	for _, item := range candidates {
+		o, err := meta.Accessor(item)
+		if err != nil {
+			continue
+		}
+		if namespace != corev1.NamespaceAll && o.GetNamespace() != namespace {
+			continue
+		}
+		if !labelSelector.Matches(labels.Set(o.GetLabels())) {
+			continue
+		}
+		if !fieldSelector.Empty() && !fieldSelector.Matches(ObjectFields(item)) {
+			continue
+		}
+		ret = append(ret, item)
+	}
+	return ret, nil
+}
+
+// candidatesFrom return the smallest index bucket covering every object the selectors may match.
+func candidatesFrom(indexer cache.Indexer, namespace string, labelSelector labels.Selector, fieldSelector fields.Selector) ([]interface{}, error) {
+	candidates, err := byNamespace(indexer, namespace)
+	if err != nil {
+		return nil, err
+	}
+	narrow := func(items []interface{}) {
+		if len(items) < len(candidates) {
+			candidates = items
+		}
+	}
+	if node, ok := fieldSelector.RequiresExactMatch("spec.nodeName"); ok {
+		if _, indexed := indexer.GetIndexers()[NodeNameIndex]; indexed {
+			items, err := indexer.ByIndex(NodeNameIndex, node)
+			if err != nil {
+				return nil, err
+			}
+			narrow(items)
+		}
+	}
+	requirements, selectable := labelSelector.Requirements()
+	if !selectable {
+		return nil, nil
+	}
+	for _, r := range requirements {
+		var values []string
+		switch r.Operator() {
+		case selection.Equals, selection.DoubleEquals, selection.In:
+			for _, v := range r.Values().List() {
+				values = append(values, LabelIndexValue(r.Key(), v))
+			}
+		case selection.Exists:
+			values = []string{r.Key()}
+		default:
+			continue
+		}
+		var items []interface{}
+		for _, v := range values {
+			bucket, err := indexer.ByIndex(LabelIndex, v)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, bucket...)
+		}
+		narrow(items)
+	}
+	return candidates, nil
+}
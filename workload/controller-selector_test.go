@@ -0,0 +1,93 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workload
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"reflect"
+	"sort"
+	"testing"
+	"x6t.io/ggp"
+)
+
+func newMockPod(namespace, name, node string, phase corev1.PodPhase, labels map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels},
+		Spec:       corev1.PodSpec{NodeName: node},
+		Status:     corev1.PodStatus{Phase: phase},
+	}
+}
+
+func TestSelectFrom(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{
+		NamespaceIndex: cache.MetaNamespaceIndexFunc,
+		LabelIndex:     LabelIndexFunc,
+		NodeNameIndex:  NodeNameIndexFunc,
+	})
+	for _, pod := range []*corev1.Pod{
+		newMockPod("edge", "mqtt-0", "node-1", corev1.PodRunning, map[string]string{"app": "mqtt", "tier": "edge"}),
+		newMockPod("edge", "mqtt-1", "node-2", corev1.PodPending, map[string]string{"app": "mqtt", "tier": "cloud"}),
+		newMockPod("edge", "mqtt-2", "node-2", corev1.PodRunning, map[string]string{"app": "mqtt", "tier": "edge", "canary": "true"}),
+		newMockPod("edge", "web-0", "node-1", corev1.PodRunning, map[string]string{"app": "web"}),
+		newMockPod("cloud", "mqtt-0", "node-3", corev1.PodRunning, map[string]string{"app": "mqtt", "tier": "cloud"}),
+	} {
+		if err := indexer.Add(pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		namespace string
+		label     string
+		field     string
+		want      []string
+	}{
+		{name: "everything", namespace: "edge", want: []string{"edge/mqtt-0", "edge/mqtt-1", "edge/mqtt-2", "edge/web-0"}},
+		{name: "equality", namespace: "edge", label: "app=mqtt", want: []string{"edge/mqtt-0", "edge/mqtt-1", "edge/mqtt-2"}},
+		{name: "all namespaces", label: "app=mqtt,tier=cloud", want: []string{"cloud/mqtt-0", "edge/mqtt-1"}},
+		{name: "set based", namespace: "edge", label: "app=mqtt,tier in (edge,cloud),!canary", want: []string{"edge/mqtt-0", "edge/mqtt-1"}},
+		{name: "notin and exists", label: "tier notin (cloud),canary", want: []string{"edge/mqtt-2"}},
+		{name: "node name", field: "spec.nodeName=node-2", want: []string{"edge/mqtt-1", "edge/mqtt-2"}},
+		{name: "phase", namespace: "edge", label: "app=mqtt", field: "status.phase!=Pending", want: []string{"edge/mqtt-0", "edge/mqtt-2"}},
+		{name: "metadata namespace", label: "app", field: "metadata.namespace=cloud", want: []string{"cloud/mqtt-0"}},
+		{name: "no match", label: "app=mosquitto", want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector, err := ggp.ParseSelector(tt.label, tt.field)
+			if err != nil {
+				t.Fatal(err)
+			}
+			items, err := selectFrom(indexer, tt.namespace, selector)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]string, 0, len(items))
+			for _, item := range items {
+				key, _ := cache.MetaNamespaceKeyFunc(item)
+				got = append(got, key)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectFrom() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workload
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+	"sync"
+	"sync/atomic"
+	"x6t.io/ggp"
+)
+
+// subscription is the changes of one kind sent to one subscriber, see Subscribe.
+// The subscriptions of an informer share its fanout, the changes are buffered in the channel of each subscription.
+type subscription struct {
+	c *controller
+	// kind is the subscribed kind, typed is its PrefixType unless it is a resource of RegisterDynamic.
+	kind  string
+	typed PrefixType
+	gvr   *schema.GroupVersionResource
+	// namespace and selector filter the changes.
+	namespace string
+	selector  ggp.Selector
+	policy    ggp.BackpressurePolicy
+	changes   chan ggp.Change
+	// done is closed by Unsubscribe, the change being sent is dropped.
+	done chan struct{}
+	once sync.Once
+	// mu guards sending to and closing changes.
+	mu      sync.Mutex
+	dropped uint64
+	// fanout is the fanout of the informer the subscription is attached to, guarded by the controller mu.
+	fanout *fanout
+}
+
+// Subscribe return the changes of the kind under this namespace matching the selector, all namespaces if empty.
+// The kind is a k8s kind such as Pod, or a resource registered by RegisterDynamic such as devices.devices.kubeedge.io.
+// The objects already cached are sent as Added first, and again after Stop or Restart as the caches are listed again.
+// The subscription lasts until Unsubscribe or until stopCh is closed, it survives Stop and Restart.
+func (c *controller) Subscribe(kind, namespace string, selector ggp.Selector, opts ...ggp.SubscribeOption) (ggp.Subscription, error) {
+	select {
+	case <-c.stopCh:
+		return nil, ErrStopped
+	default:
+	}
+	o := ggp.SubscribeOptions{BufferSize: ggp.DefaultSubscribeBufferSize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.BufferSize <= 0 {
+		o.BufferSize = ggp.DefaultSubscribeBufferSize
+	}
+	s := &subscription{
+		c:         c,
+		kind:      kind,
+		namespace: namespace,
+		selector:  selector,
+		policy:    o.Policy,
+		changes:   make(chan ggp.Change, o.BufferSize),
+		done:      make(chan struct{}),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t, ok := ParseKind(kind); ok {
+		s.typed = t
+	} else {
+		for gvr := range c.dynamicResources {
+			if dynamicName(gvr) == kind {
+				gvr := gvr
+				s.gvr = &gvr
+			}
+		}
+	}
+	if s.informer(c.informers) == nil {
+		return nil, &QueryError{Kind: kind, Namespace: namespace, Err: ErrKindDisabled}
+	}
+	if namespace != corev1.NamespaceAll && c.options.namespace != corev1.NamespaceAll && namespace != c.options.namespace {
+		return nil, &QueryError{Kind: kind, Namespace: namespace, Err: ErrNamespaceNotWatched}
+	}
+	if c.subscriptions == nil {
+		c.subscriptions = map[*subscription]bool{}
+		if c.stopCh != nil {
+			go func() {
+				<-c.stopCh
+				c.unsubscribeAll()
+			}()
+		}
+	}
+	c.subscriptions[s] = true
+	s.attach(c.informers)
+	return s, nil
+}
+
+// unsubscribeAll close every subscription once stopCh is closed.
+func (c *controller) unsubscribeAll() {
+	c.mu.RLock()
+	subscriptions := make([]*subscription, 0, len(c.subscriptions))
+	for s := range c.subscriptions {
+		subscriptions = append(subscriptions, s)
+	}
+	c.mu.RUnlock()
+	for _, s := range subscriptions {
+		s.Unsubscribe()
+	}
+}
+
+// Changes return the channel of the changes.
+func (s *subscription) Changes() <-chan ggp.Change {
+	return s.changes
+}
+
+// Dropped return the number of changes dropped as the buffer was full.
+func (s *subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Unsubscribe stop the changes and close the channel.
+func (s *subscription) Unsubscribe() {
+	s.once.Do(func() {
+		close(s.done)
+		// a blocked send returns once done is closed, then changes can be closed.
+		s.mu.Lock()
+		close(s.changes)
+		s.mu.Unlock()
+
+		s.detach()
+	})
+}
+
+// Close stop the changes and close the channel once the change being sent is buffered, unlike Unsubscribe
+// dropping it. It returns at once, the channel is closed from another goroutine.
+func (s *subscription) Close() {
+	go func() {
+		if f := s.detach(); f != nil {
+			f.wait()
+		}
+		s.Unsubscribe()
+	}()
+}
+
+// detach remove the subscription from the controller and from its fanout, no change is sent to it
+// anymore but the one being sent. It return the fanout the subscription left, nil if none.
+func (s *subscription) detach() *fanout {
+	s.c.mu.Lock()
+	delete(s.c.subscriptions, s)
+	f := s.fanout
+	s.fanout = nil
+	s.c.mu.Unlock()
+	if f != nil {
+		f.leave(s)
+	}
+	return f
+}
+
+// informer return the informer of the subscribed kind in informers, nil if it has none.
+func (s *subscription) informer(informers *Informer) cache.SharedIndexInformer {
+	if s.gvr != nil {
+		return informers.Dynamic(*s.gvr)
+	}
+	return informers.Get(s.typed)
+}
+
+// attach join the subscription to the fanout of its informer in informers, must hold the controller mu.
+// The fanout is added to the informer by its first subscription. The fanout of the replaced informers is
+// left as the informers are, notify drops their changes.
+func (s *subscription) attach(informers *Informer) {
+	informer := s.informer(informers)
+	if informer == nil {
+		return
+	}
+	if informers.fanouts == nil {
+		informers.fanouts = map[cache.SharedIndexInformer]*fanout{}
+	}
+	if f, ok := informers.fanouts[informer]; ok {
+		s.fanout = f
+		f.join(s)
+		return
+	}
+	s.fanout = newFanout(informers, informer, s)
+	informers.fanouts[informer] = s.fanout
+}
+
+// notify send the change of the object from old to new, an object entering or leaving the selector
+// is sent as Added or Deleted like the watches of the k8s apiserver.
+func (s *subscription) notify(informers *Informer, oldObj, newObj interface{}) {
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+	if s.c.getInformers() != informers {
+		return
+	}
+	oldMatch, newMatch := oldObj != nil && s.matches(oldObj), newObj != nil && s.matches(newObj)
+	change := ggp.Change{Kind: s.kind}
+	switch {
+	case oldMatch && newMatch:
+		if sameResourceVersion(oldObj, newObj) {
+			return
+		}
+		change.Type, change.Old, change.New = ggp.Modified, s.convert(oldObj), s.convert(newObj)
+	case newMatch:
+		change.Type, change.New = ggp.Added, s.convert(newObj)
+	case oldMatch:
+		change.Type, change.Old = ggp.Deleted, s.convert(oldObj)
+	default:
+		return
+	}
+	s.send(change)
+}
+
+// send the change according to the backpressure policy.
+func (s *subscription) send(change ggp.Change) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+	if s.policy == ggp.BlockPolicy {
+		select {
+		case s.changes <- change:
+		case <-s.done:
+		}
+		return
+	}
+	select {
+	case s.changes <- change:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+}
+
+// matches return true if obj is under the namespace and matches the selector of the subscription.
+func (s *subscription) matches(obj interface{}) bool {
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		return false
+	}
+	if s.namespace != corev1.NamespaceAll && o.GetNamespace() != s.namespace {
+		return false
+	}
+	if !s.c.inScope(obj) {
+		return false
+	}
+	if !s.selector.LabelSelector().Matches(labels.Set(o.GetLabels())) {
+		return false
+	}
+	fieldSelector := s.selector.FieldSelector()
+	return fieldSelector.Empty() || fieldSelector.Matches(ObjectFields(obj))
+}
+
+// convert return obj typed like the queries return it, see List.
+func (s *subscription) convert(obj interface{}) interface{} {
+	if s.gvr != nil {
+		return obj
+	}
+	return s.c.typed(s.typed, []interface{}{obj})[0]
+}
+
+// sameResourceVersion return true for the resyncs, the object is unchanged.
+func sameResourceVersion(oldObj, newObj interface{}) bool {
+	o, err := meta.Accessor(oldObj)
+	if err != nil {
+		return false
+	}
+	n, err := meta.Accessor(newObj)
+	if err != nil {
+		return false
+	}
+	return o.GetResourceVersion() != "" && o.GetResourceVersion() == n.GetResourceVersion()
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workload_test
+
+import (
+	"errors"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"sort"
+	"testing"
+	"time"
+	"x6t.io/ggp"
+	"x6t.io/ggp/ggptest"
+	"x6t.io/ggp/workload"
+)
+
+// receive return the next change of sub, the test fails if none comes in time.
+func receive(t *testing.T, sub ggp.Subscription) ggp.Change {
+	t.Helper()
+	select {
+	case change, ok := <-sub.Changes():
+		if !ok {
+			t.Fatal("Changes() closed, want a change")
+		}
+		return change
+	case <-time.After(ggptest.DefaultTimeout):
+		t.Fatal("Changes() received nothing")
+	}
+	return ggp.Change{}
+}
+
+// describeChange return the type and the pod name of change.
+func describeChange(change ggp.Change) string {
+	return fmt.Sprintf("%s %s", change.Type, change.Object().(*corev1.Pod).Name)
+}
+
+func TestSubscribe(t *testing.T) {
+	h := ggptest.NewHarness(t, []runtime.Object{newMockPod("mqtt-0", "node-1")}, workload.WithResources(workload.Pod))
+	sub, err := h.Controller.Subscribe("Pod", "edge", ggp.SelectorFromSet(map[string]string{"app": "mqtt"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+	if got := describeChange(receive(t, sub)); got != "Added mqtt-0" {
+		t.Errorf("first change = %s, want the cached mqtt-0 added", got)
+	}
+
+	other := newMockPod("mqtt-0", "node-1")
+	other.Namespace = "cloud"
+	h.Create(other, newMockPod("mqtt-1", "node-2"))
+	if got := describeChange(receive(t, sub)); got != "Added mqtt-1" {
+		t.Errorf("change = %s, want mqtt-1 added and the other namespace skipped", got)
+	}
+
+	moved := newMockPod("mqtt-0", "node-2")
+	moved.ResourceVersion = "2"
+	h.Update(moved)
+	change := receive(t, sub)
+	if change.Type != ggp.Modified || change.Old.(*corev1.Pod).Spec.NodeName != "node-1" || change.New.(*corev1.Pod).Spec.NodeName != "node-2" {
+		t.Errorf("change = %+v, want mqtt-0 modified from node-1 to node-2", change)
+	}
+
+	// leaving the selector is a deletion for the subscriber.
+	relabeled := newMockPod("mqtt-1", "node-2")
+	relabeled.Labels = map[string]string{"app": "broker"}
+	h.Update(relabeled)
+	if change := receive(t, sub); change.Type != ggp.Deleted || change.New != nil || change.Old.(*corev1.Pod).Labels["app"] != "mqtt" {
+		t.Errorf("change = %+v, want mqtt-1 deleted with its old labels", change)
+	}
+	h.Delete(moved)
+	if got := describeChange(receive(t, sub)); got != "Deleted mqtt-0" {
+		t.Errorf("change = %s, want mqtt-0 deleted", got)
+	}
+
+	sub.Unsubscribe()
+	sub.Unsubscribe()
+	if _, ok := <-sub.Changes(); ok {
+		t.Errorf("Changes() is open after Unsubscribe")
+	}
+	if _, err := h.Controller.Subscribe("Secret", "", ggp.Everything()); !errors.Is(err, workload.ErrKindDisabled) {
+		t.Errorf("Subscribe() disabled kind error = %v, want ErrKindDisabled", err)
+	}
+}
+
+func TestSubscribeBackpressure(t *testing.T) {
+	h := ggptest.NewHarness(t, nil, workload.WithResources(workload.Pod))
+	dropping, err := h.Controller.Subscribe("Pod", "", ggp.Everything(), ggp.WithBuffer(1, ggp.DropPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dropping.Unsubscribe()
+	blocking, err := h.Controller.Subscribe("Pod", "", ggp.Everything(), ggp.WithBuffer(1, ggp.BlockPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blocking.Unsubscribe()
+	fast, err := h.Controller.Subscribe("Pod", "", ggp.Everything())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fast.Unsubscribe()
+
+	h.Create(newMockPod("mqtt-0", "node-1"), newMockPod("mqtt-1", "node-1"), newMockPod("mqtt-2", "node-1"))
+	// the blocked subscriber holds up the changes of the others until it receives them.
+	for _, want := range []string{"mqtt-0", "mqtt-1", "mqtt-2"} {
+		if got := describeChange(receive(t, blocking)); got != "Added "+want {
+			t.Errorf("blocking change = %s, want %s added", got, want)
+		}
+	}
+	if got := blocking.Dropped(); got != 0 {
+		t.Errorf("blocking Dropped() = %d, want 0", got)
+	}
+	for _, want := range []string{"mqtt-0", "mqtt-1", "mqtt-2"} {
+		if got := describeChange(receive(t, fast)); got != "Added "+want {
+			t.Errorf("fast change = %s, want %s added", got, want)
+		}
+	}
+	h.Eventually(func() error {
+		if got := dropping.Dropped(); got != 2 {
+			return fmt.Errorf("Dropped() = %d, want 2", got)
+		}
+		return nil
+	})
+	if got := describeChange(receive(t, dropping)); got != "Added mqtt-0" {
+		t.Errorf("dropping change = %s, want the first change kept", got)
+	}
+}
+
+func TestSubscribeStopped(t *testing.T) {
+	stopCh := make(chan struct{})
+	c := workload.NewController(fake.NewSimpleClientset(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "edge", Name: "mqtt-0"}}), stopCh, workload.WithResources(workload.Pod))
+	sub, err := c.Subscribe("Pod", "edge", ggp.Everything(), ggp.WithBuffer(1, ggp.BlockPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(stopCh)
+	select {
+	case <-sub.Changes():
+	case <-time.After(ggptest.DefaultTimeout):
+		t.Fatal("Changes() is open after stopCh is closed")
+	}
+	if _, err := c.Subscribe("Pod", "edge", ggp.Everything()); !errors.Is(err, workload.ErrStopped) {
+		t.Errorf("Subscribe() after stop error = %v, want ErrStopped", err)
+	}
+}
+
+func TestSubscribeClose(t *testing.T) {
+	h := ggptest.NewHarness(t, []runtime.Object{newMockPod("mqtt-0", "node-1"), newMockPod("mqtt-1", "node-1")}, workload.WithResources(workload.Pod))
+	sub, err := h.Controller.Subscribe("Pod", "edge", ggp.Everything(), ggp.WithBuffer(2, ggp.BlockPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Eventually(func() error {
+		if n := len(sub.Changes()); n != 2 {
+			return fmt.Errorf("%d changes buffered, want 2", n)
+		}
+		return nil
+	})
+	sub.Close()
+	sub.Close()
+	// the buffered changes are received before the channel is closed.
+	var got []string
+	for change := range sub.Changes() {
+		got = append(got, describeChange(change))
+	}
+	sort.Strings(got)
+	if fmt.Sprint(got) != "[Added mqtt-0 Added mqtt-1]" {
+		t.Errorf("changes after Close = %v, want the cached pods added", got)
+	}
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workload
+
+import (
+	"context"
+	"fmt"
+	istio "istio.io/client-go/pkg/clientset/versioned"
+	istioscheme "istio.io/client-go/pkg/clientset/versioned/scheme"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/tools/cache"
+	"time"
+	"unicode/utf8"
+)
+
+// DropManagedFields is the transform dropping the managed fields of the objects,
+// they are often the biggest part of the metadata and never needed by the queries.
+func DropManagedFields(obj interface{}) (interface{}, error) {
+	if accessor, err := meta.Accessor(obj); err == nil {
+		accessor.SetManagedFields(nil)
+	}
+	return obj, nil
+}
+
+// DropSecretData is the transform dropping the data of the Secrets, the other objects are kept.
+// The Secrets keep their metadata and type, such as for the lookups by label or owner.
+func DropSecretData(obj interface{}) (interface{}, error) {
+	if secret, ok := obj.(*corev1.Secret); ok {
+		secret.Data = nil
+		secret.StringData = nil
+	}
+	return obj, nil
+}
+
+// TruncateAnnotations return the transform truncating the annotation values longer than limit bytes,
+// such as kubectl.kubernetes.io/last-applied-configuration holding a copy of the whole object.
+// The transform fails if limit is not positive, which would drop every annotation value, the informer then never syncs.
+func TruncateAnnotations(limit int) cache.TransformFunc {
+	return func(obj interface{}) (interface{}, error) {
+		if limit <= 0 {
+			return nil, fmt.Errorf("truncate annotations: limit %d is not positive", limit)
+		}
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return obj, nil
+		}
+		annotations := accessor.GetAnnotations()
+		for key, value := range annotations {
+			if len(value) > limit {
+				annotations[key] = truncate(value, limit)
+			}
+		}
+		return obj, nil
+	}
+}
+
+// truncate return the first limit bytes of s at most, without splitting a rune.
+func truncate(s string, limit int) string {
+	for limit > 0 && !utf8.RuneStart(s[limit]) {
+		limit--
+	}
+	return s[:limit]
+}
+
+// chainTransforms return the transform applying transforms in order.
+func chainTransforms(transforms []cache.TransformFunc) cache.TransformFunc {
+	return func(obj interface{}) (interface{}, error) {
+		for _, transform := range transforms {
+			var err error
+			if obj, err = transform(obj); err != nil {
+				return nil, err
+			}
+		}
+		return obj, nil
+	}
+}
+
+// transformObject return the object transformed by transform.
+func transformObject(transform cache.TransformFunc, obj runtime.Object) (runtime.Object, error) {
+	out, err := transform(obj)
+	if err != nil {
+		return nil, err
+	}
+	ret, ok := out.(runtime.Object)
+	if !ok {
+		return nil, fmt.Errorf("transform %T: got %T, want a runtime.Object", obj, out)
+	}
+	return ret, nil
+}
+
+// transformingListWatch return lw whose listed and watched objects are transformed before they reach the store.
+func transformingListWatch(lw *cache.ListWatch, tweak func(*metav1.ListOptions), transform cache.TransformFunc) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			if tweak != nil {
+				tweak(&options)
+			}
+			list, err := lw.List(options)
+			if err != nil {
+				return nil, err
+			}
+			items, err := meta.ExtractList(list)
+			if err != nil {
+				return nil, err
+			}
+			for i := range items {
+				if items[i], err = transformObject(transform, items[i]); err != nil {
+					return nil, err
+				}
+			}
+			if err := meta.SetList(list, items); err != nil {
+				return nil, err
+			}
+			return list, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			if tweak != nil {
+				tweak(&options)
+			}
+			w, err := lw.Watch(options)
+			if err != nil {
+				return nil, err
+			}
+			return watch.Filter(w, func(event watch.Event) (watch.Event, bool) {
+				if event.Type == watch.Error || event.Type == watch.Bookmark {
+					return event, true
+				}
+				obj, err := transformObject(transform, event.Object)
+				if err != nil {
+					return errorEvent(err), true
+				}
+				event.Object = obj
+				return event, true
+			}), nil
+		},
+	}
+}
+
+// errorEvent return the watch error event of err, the reflector then lists and watches again.
+func errorEvent(err error) watch.Event {
+	return watch.Event{Type: watch.Error, Object: &metav1.Status{
+		Status:  metav1.StatusFailure,
+		Message: err.Error(),
+		Reason:  metav1.StatusReasonInternalError,
+		Code:    500,
+	}}
+}
+
+// newTransformingInformer create the informer of the kind served as the resource r whose objects are transformed
+// before they are stored, the informer of the metadata only kinds if metadataClient is not nil. The objects of r
+// are converted to the preferred resource of the kind first, see kindResource, transform may then be nil.
+// The informers of client-go do not take a transform before v0.24, so the informer is built on its own list watch.
+func newTransformingInformer(client kubernetes.Interface, istioClient istio.Interface, metadataClient metadata.Interface, kind PrefixType, r kindResource,
+	namespace string, resync time.Duration, indexers cache.Indexers, tweak func(*metav1.ListOptions), transform cache.TransformFunc) (cache.SharedIndexInformer, error) {
+	var lw *cache.ListWatch
+	var objType runtime.Object
+	if metadataClient != nil {
+		lw, objType = newMetadataListWatch(metadataClient, r.gvr, namespace), &metav1.PartialObjectMetadata{}
+	} else {
+		objScheme := scheme.Scheme
+		if kinds[kind].istio {
+			objScheme = istioscheme.Scheme
+		}
+		lw = r.listWatch(client, istioClient, namespace)
+		var err error
+		if r.conversion != nil {
+			lw, objType = convertingListWatch(lw, r.conversion), r.conversion.objType
+		} else if objType, err = objScheme.New(r.gvr.GroupVersion().WithKind(kind.String())); err != nil {
+			return nil, err
+		}
+	}
+	if transform == nil {
+		transform = func(obj interface{}) (interface{}, error) { return obj, nil }
+	}
+	return cache.NewSharedIndexInformer(transformingListWatch(lw, tweak, transform), objType, resync, indexers), nil
+}
+
+// newMetadataListWatch return the list watch of the metadata of gvr.
+func newMetadataListWatch(client metadata.Interface, gvr schema.GroupVersionResource, namespace string) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return client.Resource(gvr).Namespace(namespace).List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.Resource(gvr).Namespace(namespace).Watch(context.TODO(), options)
+		},
+	}
+}
+
+// newListWatch return the list watch of the List and Watch methods of a typed client.
+func newListWatch(list func(context.Context, metav1.ListOptions) (runtime.Object, error),
+	watchFunc func(context.Context, metav1.ListOptions) (watch.Interface, error)) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return list(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return watchFunc(context.TODO(), options)
+		},
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workload
+
+import (
+	"context"
+	"errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newMockTransformMeta(name string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Namespace:     "edge",
+		Name:          name,
+		Annotations:   map[string]string{"kubectl.kubernetes.io/last-applied-configuration": strings.Repeat("x", 64), "app": "mqtt"},
+		ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationApply}},
+	}
+}
+
+func TestTransform(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&corev1.Pod{ObjectMeta: newMockTransformMeta("mqtt-0")},
+		&corev1.Secret{ObjectMeta: newMockTransformMeta("mqtt-tls"), Data: map[string][]byte{"tls.key": []byte("key")}},
+	)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	c := NewController(clientset, stopCh,
+		WithResources(Pod, Secret, ConfigMap),
+		WithTransform(DropManagedFields),
+		WithTransform(TruncateAnnotations(8)),
+		WithTransform(DropSecretData, Secret)).(*controller)
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	pod, err := c.GetPod("edge", "mqtt-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pod.ManagedFields != nil || len(pod.Annotations["kubectl.kubernetes.io/last-applied-configuration"]) != 8 || pod.Annotations["app"] != "mqtt" {
+		t.Errorf("GetPod() meta = %+v, want no managed fields and the annotations truncated to 8 bytes", pod.ObjectMeta)
+	}
+	secret, err := c.GetSecret("edge", "mqtt-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Data != nil || secret.ManagedFields != nil {
+		t.Errorf("GetSecret() = %+v, want no data and no managed fields", secret)
+	}
+
+	// the watched objects are transformed too.
+	cm := &corev1.ConfigMap{ObjectMeta: newMockTransformMeta("mqtt"), Data: map[string]string{"mqtt.conf": "listener 1883"}}
+	if _, err := clientset.CoreV1().ConfigMaps("edge").Create(context.Background(), cm, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	var got *corev1.ConfigMap
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		got, err = c.GetConfigMap("edge", "mqtt")
+		return err == nil, nil
+	}); err != nil {
+		t.Fatalf("GetConfigMap() error = %v", err)
+	}
+	if got.ManagedFields != nil || got.Data["mqtt.conf"] != "listener 1883" {
+		t.Errorf("GetConfigMap() = %+v, want no managed fields and the data kept", got)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s     string
+		limit int
+		want  string
+	}{
+		{"mqtt", 2, "mq"},
+		{"héllo", 2, "h"},
+		{"héllo", 3, "hé"},
+		{"mqtt", 0, ""},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.s, tt.limit); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateAnnotationsNonPositive(t *testing.T) {
+	for _, limit := range []int{0, -1} {
+		if _, err := TruncateAnnotations(limit)(&corev1.Pod{ObjectMeta: newMockTransformMeta("mqtt-0")}); err == nil {
+			t.Errorf("TruncateAnnotations(%d) error = nil, want the limit rejected", limit)
+		}
+	}
+
+	// the pods can not be listed, the informer never syncs.
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	c := NewController(fake.NewSimpleClientset(&corev1.Pod{ObjectMeta: newMockTransformMeta("mqtt-0")}), stopCh,
+		WithResources(Pod), WithTransform(TruncateAnnotations(0)), WithSyncTimeout(100*time.Millisecond))
+	var syncErr *SyncError
+	if err := c.Start(context.Background()); !errors.As(err, &syncErr) || len(syncErr.Informers) != 1 || syncErr.Informers[0] != "Pod" {
+		t.Errorf("Start() error = %v, want the pod informer not synced", err)
+	}
+}
+
+func TestTransformBuildError(t *testing.T) {
+	// the objects of an unknown version can not be created, so the transforming informer is not built.
+	info := kinds[Pod]
+	unknown := info.resources[0]
+	unknown.gvr.Version = "v0"
+	kinds[Pod] = kindInfo{name: info.name, resources: []kindResource{unknown}}
+	defer func() { kinds[Pod] = info }()
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	c := NewController(fake.NewSimpleClientset(), stopCh, WithResources(Pod, Secret), WithTransform(DropManagedFields)).(*controller)
+	if err := c.Start(context.Background()); err == nil {
+		t.Fatal("Start() error = nil, want the build error of the pod informer")
+	}
+	var failed []string
+	for _, status := range c.SyncStatus() {
+		if status.Err != nil {
+			failed = append(failed, status.Name)
+		}
+	}
+	if len(failed) != 1 || failed[0] != "Pod" {
+		t.Errorf("SyncStatus() failed = %v, want [Pod]", failed)
+	}
+}
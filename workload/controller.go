@@ -17,17 +17,19 @@ limitations under the License.
 package workload
 
 import (
-	appsv1 "k8s.io/api/apps/v1"
-	autoscalingv2 "k8s.io/api/autoscaling/v2beta2"
+	"context"
+	"fmt"
 	corev1 "k8s.io/api/core/v1"
-	extensions "k8s.io/api/extensions/v1beta1"
-	storagev1 "k8s.io/api/storage/v1"
-	"k8s.io/client-go/informers"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	"sync"
 	"time"
 	"x6t.io/ggp"
+	"x6t.io/ggp/client"
 )
 
 const (
@@ -35,296 +37,472 @@ const (
 	DefaultResyncPeriod = time.Second * 10
 	// DefaultStorageClassResyncPeriod is default k8s StorageClass synchronised time.
 	DefaultStorageClassResyncPeriod = time.Second * 300
+	// DefaultSyncTimeout is default time Start waits for the informers to sync.
+	DefaultSyncTimeout = time.Minute * 2
+	// syncPollPeriod is how often Start checks the informers sync status.
+	syncPollPeriod = time.Millisecond * 100
 )
 
 type controller struct {
 	// client is k8s client.
 	client kubernetes.Interface
+	// manager is the client of NewManagerController, Restart reloads the clients from it.
+	manager *client.ManagerClient
+	// discovery tells the versions of Ingress and HorizontalPodAutoscaler served by the apiserver.
+	discovery *client.Discovery
+	// options is the options of NewController.
+	options *options
 	// informers is k8s informer.
 	informers *Informer
 	// listers is k8s lister.
 	listers *Lister
 	// stopCh is stop channel.
 	stopCh <-chan struct{}
-	// cachesMap is k8s caches cr.
+	// cachesMap is the event timelines of the involved objects, see controller-event.go.
 	cachesMap sync.Map
+	// mu guards client, informers, listers and runCh, they are replaced by Stop and Restart.
+	mu sync.RWMutex
+	// runCh is closed to stop the running informers, nil if they are not running.
+	runCh chan struct{}
+	// dynamicResources is the resources registered by RegisterDynamic and whether they are namespaced.
+	dynamicResources map[schema.GroupVersionResource]bool
+	// subscriptions is the subscriptions of Subscribe, attached again to the informers built by Stop and Restart.
+	subscriptions map[*subscription]bool
+	// versions is the served resource of the kinds served in several versions, see servedVersions.
+	// It is nil until Start or Restart resolve it, the preferred versions are informed meanwhile.
+	versions map[PrefixType]kindResource
 }
 
 // NewController stopCh is context.Done.
-func NewController(clientset kubernetes.Interface, stopCh <-chan struct{}) ggp.ControllerService {
+// All k8s kinds are informed cluster wide by default, see Option to narrow them.
+// The istio kinds need WithIstioClient, or use NewManagerController.
+// The served versions of Ingress and HorizontalPodAutoscaler are discovered by Start, NewController does not
+// reach the apiserver.
+func NewController(clientset kubernetes.Interface, stopCh <-chan struct{}, opts ...Option) ggp.ControllerService {
+	return newController(clientset, client.NewDiscovery(clientset.Discovery()), stopCh, opts...)
+}
+
+func newController(clientset kubernetes.Interface, discovery *client.Discovery, stopCh <-chan struct{}, opts ...Option) *controller {
 	c := &controller{
 		client:    clientset,
-		informers: &Informer{},
-		listers:   &Lister{},
+		discovery: discovery,
+		options:   newOptions(opts...),
 		stopCh:    stopCh,
 		cachesMap: sync.Map{},
 	}
-
-	// create informers factory, enable and assign required informers
-	infoFactory := informers.NewSharedInformerFactory(clientset, DefaultResyncPeriod)
-	c.informers.Namespace = infoFactory.Core().V1().Namespaces().Informer()
-	// informer ingress
-	c.informers.Ingress = infoFactory.Extensions().V1beta1().Ingresses().Informer()
-	c.listers.Ingress = infoFactory.Extensions().V1beta1().Ingresses().Lister()
-	// informer service
-	c.informers.Service = infoFactory.Core().V1().Services().Informer()
-	c.listers.Service = infoFactory.Core().V1().Services().Lister()
-	// informer secret
-	c.informers.Secret = infoFactory.Core().V1().Secrets().Informer()
-	c.listers.Secret = infoFactory.Core().V1().Secrets().Lister()
-	// informer StatefulSet
-	c.informers.StatefulSet = infoFactory.Apps().V1().StatefulSets().Informer()
-	c.listers.StatefulSet = infoFactory.Apps().V1().StatefulSets().Lister()
-	// informer deployment
-	c.informers.Deployment = infoFactory.Apps().V1().Deployments().Informer()
-	c.listers.Deployment = infoFactory.Apps().V1().Deployments().Lister()
-	// informer pod
-	c.informers.Pod = infoFactory.Core().V1().Pods().Informer()
-	c.listers.Pod = infoFactory.Core().V1().Pods().Lister()
-	// informer ConfigMap
-	c.informers.ConfigMap = infoFactory.Core().V1().ConfigMaps().Informer()
-	c.listers.ConfigMap = infoFactory.Core().V1().ConfigMaps().Lister()
-	// informer ReplicaSet
-	c.informers.ReplicaSet = infoFactory.Apps().V1().ReplicaSets().Informer()
-	// informer Endpoints
-	c.informers.Endpoints = infoFactory.Core().V1().Endpoints().Informer()
-	c.listers.Endpoints = infoFactory.Core().V1().Endpoints().Lister()
-	// informer Nodes
-	c.informers.Nodes = infoFactory.Core().V1().Nodes().Informer()
-	c.listers.Nodes = infoFactory.Core().V1().Nodes().Lister()
-	// informer StorageClass
-	c.informers.StorageClass = infoFactory.Storage().V1().StorageClasses().Informer()
-	c.listers.StorageClass = infoFactory.Storage().V1().StorageClasses().Lister()
-	// informer Claims
-	c.informers.Claims = infoFactory.Core().V1().PersistentVolumeClaims().Informer()
-	c.listers.Claims = infoFactory.Core().V1().PersistentVolumeClaims().Lister()
-	// informer Events
-	c.informers.Events = infoFactory.Core().V1().Events().Informer()
-	// informer HorizontalPodAutoscaler
-	c.informers.HorizontalPodAutoscaler = infoFactory.Autoscaling().V2beta2().HorizontalPodAutoscalers().Informer()
-	c.listers.HorizontalPodAutoscaler = infoFactory.Autoscaling().V2beta2().HorizontalPodAutoscalers().Lister()
-
-	// add event handler
-	c.informers.Namespace.AddEventHandler(c.AddNameSpaceEventHandler())
-	c.informers.Ingress.AddEventHandlerWithResyncPeriod(c, DefaultResyncPeriod)
-	c.informers.Service.AddEventHandlerWithResyncPeriod(c, DefaultResyncPeriod)
-	c.informers.Secret.AddEventHandlerWithResyncPeriod(c, DefaultResyncPeriod)
-	c.informers.StatefulSet.AddEventHandlerWithResyncPeriod(c, DefaultResyncPeriod)
-	c.informers.Deployment.AddEventHandlerWithResyncPeriod(c, DefaultResyncPeriod)
-	c.informers.Pod.AddEventHandlerWithResyncPeriod(c.AddPodEventHandler(), DefaultResyncPeriod)
-	c.informers.ConfigMap.AddEventHandlerWithResyncPeriod(c, DefaultResyncPeriod)
-	c.informers.ReplicaSet.AddEventHandlerWithResyncPeriod(c, DefaultResyncPeriod)
-	c.informers.Endpoints.AddEventHandlerWithResyncPeriod(c.AddEndpointsEventHandler(), DefaultResyncPeriod)
-	c.informers.Nodes.AddEventHandlerWithResyncPeriod(c, DefaultResyncPeriod)
-	c.informers.StorageClass.AddEventHandlerWithResyncPeriod(c, DefaultStorageClassResyncPeriod)
-	c.informers.Claims.AddEventHandlerWithResyncPeriod(c, DefaultResyncPeriod)
-	c.informers.Events.AddEventHandlerWithResyncPeriod(c, DefaultResyncPeriod)
-	c.informers.HorizontalPodAutoscaler.AddEventHandlerWithResyncPeriod(c, DefaultResyncPeriod)
+	c.build()
 	return c
 }
 
-func (c *controller) Ready() bool {
-	return c.informers.Ready()
-}
+// build create the informers and the listers of the enabled kinds against c.client, must hold mu.
+// The kinds served in several versions are informed in their resolved version, see resolveVersions.
+func (c *controller) build() {
+	o := c.options
+	informers := &Informer{}
 
-func (c *controller) Start() error {
-	c.informers.Start(c.stopCh)
-	if !c.Ready() {
-		// keep blocking if not ready.
+	// create the enabled informers, the indexers must be added before they start.
+	for _, kind := range o.enabledKinds() {
+		indexers := DefaultIndexers()
+		indexers[NamespaceIndex] = cache.MetaNamespaceIndexFunc
+		if kind == Pod {
+			indexers[NodeNameIndex] = NodeNameIndexFunc
+		}
+		if kinds[kind].istio && o.istioClient == nil {
+			informers.setError(kind, ErrNoIstioClient)
+			continue
+		}
+		r := preferredResource(kind)
+		if served, ok := c.versions[kind]; ok {
+			r = served
+		}
+		// Start and SyncStatus report the informers which can not be built, such as a failing transform.
+		informer, err := newKindInformer(c.client, o, kind, r, indexers)
+		if err != nil {
+			informers.setError(kind, err)
+			continue
+		}
+		if o.metadataOnly(kind) {
+			informers.setMetadataOnly(kind, r.gvr.GroupVersion(), informer)
+			continue
+		}
+		informers.setVersioned(kind, r.gvr.GroupVersion(), informer)
 	}
-	return nil
-}
 
-func (c *controller) OnAdd(obj interface{}) {
-	// ingress
-	if ingress, ok := obj.(*extensions.Ingress); ok {
-		if list, ok := c.cachesMap.Load(c.Prefix(Ingress, ingress.Namespace)); ok {
-			list = append(list.([]*extensions.Ingress), ingress)
-			c.cachesMap.Store(c.Prefix(Ingress, ingress.Namespace), list)
-		} else {
-			c.cachesMap.Store(c.Prefix(Ingress, ingress.Namespace), []*extensions.Ingress{ingress})
+	// add event handler, the events of replaced informers still in flight are dropped.
+	for _, kind := range o.enabledKinds() {
+		informer := informers.Get(kind)
+		if informer == nil {
+			continue
+		}
+		var handler cache.ResourceEventHandler = currentHandler{c: c, informers: informers}
+		if kind != NameSpace && o.namespaceSelector != nil {
+			handler = cache.FilteringResourceEventHandler{FilterFunc: c.inScope, Handler: handler}
 		}
+		informer.AddEventHandlerWithResyncPeriod(handler, o.resyncFor(kind))
 	}
+	for gvr, namespaced := range c.dynamicResources {
+		c.buildDynamic(informers, gvr, namespaced)
+	}
+	for s := range c.subscriptions {
+		s.attach(informers)
+	}
+	c.informers = informers
+	c.listers = newLister(informers)
+}
 
-	// service
-	if service, ok := obj.(*corev1.Service); ok {
-		if list, ok := c.cachesMap.Load(c.Prefix(Service, service.Namespace)); ok {
-			list = append(list.([]*corev1.Service), service)
-			c.cachesMap.Store(c.Prefix(Service, service.Namespace), list)
-		} else {
-			c.cachesMap.Store(c.Prefix(Service, service.Namespace), []*corev1.Service{service})
+// servedVersions return the first served resource of the enabled kinds served as several resources,
+// the preferred one if none is served or the discovery fails, such as when the apiserver is unreachable.
+// The discovery may query the apiserver, mu must not be held.
+func (c *controller) servedVersions(discovery *client.Discovery) map[PrefixType]kindResource {
+	ret := map[PrefixType]kindResource{}
+	for _, kind := range c.options.enabledKinds() {
+		resources := kinds[kind].resources
+		if len(resources) < 2 {
+			continue
+		}
+		ret[kind] = resources[0]
+		for _, r := range resources {
+			served, err := discovery.IsServed(r.gvr)
+			if err != nil {
+				utilruntime.HandleError(fmt.Errorf("discover %s: %v", r.gvr, err))
+				break
+			}
+			if served {
+				ret[kind] = r
+				break
+			}
 		}
 	}
+	return ret
+}
 
-	// secret
-	if secret, ok := obj.(*corev1.Secret); ok {
-		if list, ok := c.cachesMap.Load(c.Prefix(Secret, secret.Namespace)); ok {
-			list = append(list.([]*corev1.Secret), secret)
-			c.cachesMap.Store(c.Prefix(Secret, secret.Namespace), list)
-		} else {
-			c.cachesMap.Store(c.Prefix(Secret, secret.Namespace), []*corev1.Secret{secret})
-		}
+// resolveVersions discover the served versions once, then rebuild the informers if they are not running
+// and inform other versions. The discovery runs before mu is held.
+func (c *controller) resolveVersions() {
+	c.mu.RLock()
+	discovery, resolved := c.discovery, c.versions != nil
+	c.mu.RUnlock()
+	if resolved {
+		return
 	}
+	versions := c.servedVersions(discovery)
 
-	// StatefulSet
-	if statefulset, ok := obj.(*appsv1.StatefulSet); ok {
-		if list, ok := c.cachesMap.Load(c.Prefix(StatefulSet, statefulset.Namespace)); ok {
-			list = append(list.([]*appsv1.StatefulSet), statefulset)
-			c.cachesMap.Store(c.Prefix(StatefulSet, statefulset.Namespace), list)
-		} else {
-			c.cachesMap.Store(c.Prefix(StatefulSet, statefulset.Namespace), []*appsv1.StatefulSet{statefulset})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Restart or another Start resolved them meanwhile.
+	if c.versions != nil || c.discovery != discovery {
+		return
+	}
+	c.versions = versions
+	if c.runCh != nil {
+		return
+	}
+	for kind, r := range versions {
+		if c.informers.Get(kind) != nil && c.informers.GroupVersion(kind) != r.gvr.GroupVersion() {
+			c.build()
+			return
 		}
 	}
+}
 
-	// deployment
-	if deployment, ok := obj.(*appsv1.Deployment); ok {
-		if list, ok := c.cachesMap.Load(c.Prefix(Deployment, deployment.Namespace)); ok {
-			list = append(list.([]*appsv1.Deployment), deployment)
-			c.cachesMap.Store(c.Prefix(Deployment, deployment.Namespace), list)
-		} else {
-			c.cachesMap.Store(c.Prefix(Deployment, deployment.Namespace), []*appsv1.Deployment{deployment})
-		}
+// getInformers return the current informers.
+func (c *controller) getInformers() *Informer {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.informers
+}
+
+// getListers return the current listers.
+func (c *controller) getListers() *Lister {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.listers
+}
+
+// NewManagerController return the controller informing the k8s kinds and the istio kinds of client.
+// Restart reloads the k8s and istio clients from client, it is called each time client is reloaded
+// until stopCh is closed, see client.ManagerClient.Watch.
+func NewManagerController(client *client.ManagerClient, stopCh <-chan struct{}, opts ...Option) ggp.ControllerService {
+	managerOpts := []Option{
+		WithIstioClient(client.IstioClient()),
+		WithMetadataClient(client.MetadataClient()),
+		WithDynamicClient(client.DynamicClient()),
+	}
+	c := newController(client.KubeClient(), client.Discovery(), stopCh, append(managerOpts, opts...)...)
+	c.manager = client
+	remove := client.OnReload(func() {
+		_ = c.Restart(nil)
+	})
+	// a nil stopCh is never closed, the controller then keeps following the reloads.
+	if stopCh != nil {
+		go func() {
+			<-stopCh
+			remove()
+		}()
 	}
+	return c
+}
 
-	// configMap
-	if configmap, ok := obj.(*corev1.ConfigMap); ok {
-		if list, ok := c.cachesMap.Load(c.Prefix(ConfigMap, configmap.Namespace)); ok {
-			list = append(list.([]*corev1.ConfigMap), configmap)
-			c.cachesMap.Store(c.Prefix(ConfigMap, configmap.Namespace), list)
-		} else {
-			c.cachesMap.Store(c.Prefix(ConfigMap, configmap.Namespace), []*corev1.ConfigMap{configmap})
-		}
+// inScope return false if obj is namespaced and its namespace is not selected by the namespace selector.
+func (c *controller) inScope(obj interface{}) bool {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	o, err := meta.Accessor(obj)
+	if err != nil {
+		return false
+	}
+	return c.namespaceInScope(o.GetNamespace())
+}
+
+// namespaceInScope return true if the objects under namespace are kept.
+func (c *controller) namespaceInScope(namespace string) bool {
+	if c.options.namespaceSelector == nil || namespace == corev1.NamespaceAll {
+		return true
 	}
+	// nothing is in scope if the Namespace kind is not informed.
+	informer := c.getInformers().Namespace
+	if informer == nil {
+		return false
+	}
+	_, exists, err := informer.GetIndexer().GetByKey(namespace)
+	return err == nil && exists
+}
+
+func (c *controller) Ready() bool {
+	return c.getInformers().Ready()
+}
 
-	// ReplicaSet
-	if replicaset, ok := obj.(*appsv1.ReplicaSet); ok {
-		if list, ok := c.cachesMap.Load(c.Prefix(ReplicaSet, replicaset.Namespace)); ok {
-			list = append(list.([]*appsv1.ReplicaSet), replicaset)
-			c.cachesMap.Store(c.Prefix(ReplicaSet, replicaset.Namespace), list)
-		} else {
-			c.cachesMap.Store(c.Prefix(ReplicaSet, replicaset.Namespace), []*appsv1.ReplicaSet{replicaset})
+// Start run the informers until Stop is called or stopCh is closed, and block until they have synced.
+// It gives up when ctx is done or the sync timeout expires, returning a *SyncError.
+// Calling Start on running informers only waits for them to sync. Nothing runs if the informer of
+// an enabled kind could not be built, such as an istio kind without istio client, the reasons are returned.
+func (c *controller) Start(ctx context.Context) error {
+	select {
+	case <-c.stopCh:
+		return ErrStopped
+	default:
+	}
+	c.resolveVersions()
+	c.mu.Lock()
+	if err := c.informers.err(); err != nil {
+		c.mu.Unlock()
+		return err
+	}
+	if c.runCh == nil {
+		c.run()
+	}
+	c.mu.Unlock()
+
+	if c.options.syncTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.options.syncTimeout)
+		defer cancel()
+	}
+	err := wait.PollImmediateUntil(syncPollPeriod, func() (bool, error) {
+		select {
+		case <-c.stopCh:
+			return false, ErrStopped
+		default:
+			return c.Ready(), nil
 		}
+	}, ctx.Done())
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		err = ctx.Err()
 	}
+	syncErr := &SyncError{Err: err}
+	for _, status := range c.SyncStatus() {
+		if !status.Synced {
+			syncErr.Informers = append(syncErr.Informers, status.Name)
+		}
+	}
+	return syncErr
+}
 
-	// StorageClass
-	if sc, ok := obj.(*storagev1.StorageClass); ok {
-		if list, ok := c.cachesMap.Load(c.Prefix(StorageClass, sc.Namespace)); ok {
-			list = append(list.([]*storagev1.StorageClass), sc)
-			c.cachesMap.Store(c.Prefix(StorageClass, sc.Namespace), list)
-		} else {
-			c.cachesMap.Store(c.Prefix(StorageClass, sc.Namespace), []*storagev1.StorageClass{sc})
+// run start the informers until runCh or stopCh is closed, must hold mu.
+func (c *controller) run() {
+	runCh := make(chan struct{})
+	c.runCh = runCh
+	c.informers.Start(runCh)
+	if c.informers.Events != nil && c.options.eventTTL > 0 {
+		go wait.Until(c.evictEvents, DefaultEventEvictPeriod, runCh)
+	}
+	go func() {
+		select {
+		case <-c.stopCh:
+			c.mu.Lock()
+			c.shutdown()
+			c.mu.Unlock()
+		case <-runCh:
 		}
+	}()
+}
+
+// Stop stop the running informers and clear the caches, the controller can be started again.
+// The informers are replaced by new unsynced ones, so queries return nothing until the next Start.
+func (c *controller) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.stopCh:
+		// the controller can not be started again, there is nothing to build.
+		c.shutdown()
+	default:
+		c.stop()
 	}
+}
 
-	// Claims
-	if claim, ok := obj.(*corev1.PersistentVolumeClaim); ok {
-		if list, ok := c.cachesMap.Load(c.Prefix(PersistentVolumeClaim, claim.Namespace)); ok {
-			list = append(list.([]*corev1.PersistentVolumeClaim), claim)
-			c.cachesMap.Store(c.Prefix(PersistentVolumeClaim, claim.Namespace), list)
-		} else {
-			c.cachesMap.Store(c.Prefix(PersistentVolumeClaim, claim.Namespace), []*corev1.PersistentVolumeClaim{claim})
+// stop must hold mu.
+func (c *controller) stop() {
+	c.shutdown()
+	c.build()
+}
+
+// shutdown stop the running informers and clear the caches without building new ones, must hold mu.
+func (c *controller) shutdown() {
+	if c.runCh != nil {
+		close(c.runCh)
+		c.runCh = nil
+	}
+	// the timelines still held by an eviction are removed too, so it does not delete the new ones.
+	c.cachesMap.Range(func(key, v interface{}) bool {
+		if timeline, ok := v.(*eventTimeline); ok {
+			timeline.mu.Lock()
+			timeline.removed = true
+			timeline.mu.Unlock()
 		}
+		c.cachesMap.Delete(key)
+		return true
+	})
+}
+
+// Restart stop the informers and list again against clientset, such as after switching cluster or upgrading it.
+// A nil clientset keeps the current one, or reloads it for a controller of NewManagerController.
+// The informers run again if they were running, call Start to wait for them to sync.
+func (c *controller) Restart(clientset kubernetes.Interface) error {
+	select {
+	case <-c.stopCh:
+		return ErrStopped
+	default:
+	}
+	c.mu.RLock()
+	discovery := c.discovery
+	c.mu.RUnlock()
+	if c.manager != nil {
+		discovery = c.manager.Discovery()
+	} else if clientset != nil {
+		discovery = client.NewDiscovery(clientset.Discovery())
 	}
+	// the apiserver may have been upgraded, the served versions are discovered again before mu is held.
+	discovery.Invalidate()
+	versions := c.servedVersions(discovery)
 
-	// event
-	if event, ok := obj.(*corev1.Event); ok {
-		if list, ok := c.cachesMap.Load(c.Prefix(Event, event.Namespace, event.InvolvedObject.Kind, event.InvolvedObject.Name)); ok {
-			list = append(list.([]*corev1.Event), event)
-			c.cachesMap.Store(c.Prefix(Event, event.Namespace, event.InvolvedObject.Kind, event.InvolvedObject.Name), list)
-		} else {
-			c.cachesMap.Store(c.Prefix(Event, event.Namespace, event.InvolvedObject.Kind, event.InvolvedObject.Name), []*corev1.Event{event})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.manager != nil {
+		if clientset == nil {
+			clientset = c.manager.KubeClient()
 		}
+		c.options.istioClient = c.manager.IstioClient()
+		c.options.metadataClient = c.manager.MetadataClient()
+		c.options.dynamicClient = c.manager.DynamicClient()
+	}
+	if clientset != nil {
+		c.client = clientset
 	}
+	c.discovery = discovery
+	c.versions = versions
+	running := c.runCh != nil
+	c.stop()
+	if running {
+		c.run()
+	}
+	return nil
+}
 
-	// HorizontalPodAutoscaler
-	if hpa, ok := obj.(*autoscalingv2.HorizontalPodAutoscaler); ok {
-		if list, ok := c.cachesMap.Load(c.Prefix(HorizontalPodAutoscaler, hpa.Namespace)); ok {
-			list = append(list.([]*autoscalingv2.HorizontalPodAutoscaler), hpa)
-			c.cachesMap.Store(c.Prefix(HorizontalPodAutoscaler, hpa.Namespace), list)
-		} else {
-			c.cachesMap.Store(c.Prefix(HorizontalPodAutoscaler, hpa.Namespace), []*autoscalingv2.HorizontalPodAutoscaler{hpa})
+// SyncStatus return the sync status of every enabled informer, with the reason of those which could not be built.
+func (c *controller) SyncStatus() []ggp.SyncStatus {
+	ret := make([]ggp.SyncStatus, 0)
+	informers := c.getInformers()
+	groupVersions := map[cache.SharedIndexInformer]string{}
+	metadataOnly := map[cache.SharedIndexInformer]bool{}
+	for _, kind := range c.options.enabledKinds() {
+		if informer := informers.Get(kind); informer != nil {
+			groupVersions[informer] = informers.GroupVersion(kind).String()
+			metadataOnly[informer] = informers.MetadataOnly(kind)
 		}
 	}
+	informers.eachDynamic(func(gvr schema.GroupVersionResource, informer cache.SharedIndexInformer) {
+		groupVersions[informer] = gvr.GroupVersion().String()
+	})
+	informers.Each(func(name string, informer cache.SharedIndexInformer) {
+		ret = append(ret, ggp.SyncStatus{
+			Name:            name,
+			Synced:          informer.HasSynced(),
+			ResourceVersion: informer.LastSyncResourceVersion(),
+			GroupVersion:    groupVersions[informer],
+			MetadataOnly:    metadataOnly[informer],
+		})
+	})
+	informers.eachError(func(name string, err error) {
+		ret = append(ret, ggp.SyncStatus{Name: name, Err: err})
+	})
+	return ret
 }
 
-func (c *controller) OnUpdate(oldObj, newObj interface{}) {
+// OnAdd the events are stored in the timeline of their involved object in cachesMap.
+// Other kinds are queried from the indexed informer stores, see controller-index.go.
+func (c *controller) OnAdd(obj interface{}) {
+	if event, ok := obj.(*corev1.Event); ok {
+		c.putEvent(event)
+	}
+}
 
+// OnUpdate the old event is replaced by the new one in its timeline.
+func (c *controller) OnUpdate(oldObj, newObj interface{}) {
+	if event, ok := newObj.(*corev1.Event); ok {
+		c.putEvent(event)
+	}
 }
 
+// OnDelete the event is removed from its timeline.
 func (c *controller) OnDelete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if event, ok := obj.(*corev1.Event); ok {
+		c.deleteEvent(event)
+	}
+}
 
+// currentHandler is the event handler of the controller on informers, the events are dropped once informers
+// are replaced by Stop or Restart. The controller mu is read locked while an event is handled, so the timelines
+// are not cleared by shutdown in between.
+type currentHandler struct {
+	c         *controller
+	informers *Informer
 }
 
-// AddNameSpaceEventHandler return namespace event handler.
-func (c *controller) AddNameSpaceEventHandler() cache.ResourceEventHandlerFuncs {
-	return cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj interface{}) {
-			ns := obj.(*corev1.Namespace)
-			c.cachesMap.Store(NameSpacePrefix(ns.Name), ns)
-		},
-		DeleteFunc: func(obj interface{}) {
-			ns := obj.(*corev1.Namespace)
-			c.cachesMap.Delete(NameSpacePrefix(ns.Name))
-		},
-		UpdateFunc: func(oldObj, newObj interface{}) {
-			ns := newObj.(*corev1.Namespace)
-			c.cachesMap.Store(NameSpacePrefix(ns.Name), ns)
-		},
+// handle call fn if informers are the current ones.
+func (h currentHandler) handle(fn func()) {
+	h.c.mu.RLock()
+	defer h.c.mu.RUnlock()
+	if h.c.informers == h.informers {
+		fn()
 	}
 }
 
-// AddPodEventHandler return pod event handler.
-func (c *controller) AddPodEventHandler() cache.ResourceEventHandlerFuncs {
-	return cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj interface{}) {
-			pod := obj.(*corev1.Pod)
-			if list, ok := c.cachesMap.Load(c.Prefix(Pod, pod.Namespace)); ok {
-				list = append(list.([]*corev1.Pod), pod)
-				c.cachesMap.Store(c.Prefix(Pod, pod.Namespace), list)
-			} else {
-				c.cachesMap.Store(c.Prefix(Pod, pod.Namespace), []*corev1.Pod{pod})
-			}
-		},
-		DeleteFunc: func(obj interface{}) {
-			pod := obj.(*corev1.Pod)
-			if list, ok := c.cachesMap.Load(PodSpacePrefix(pod.Namespace)); ok {
-				for i, pods := range list.([]*corev1.Pod) {
-					if pods.Name == pod.Name {
-						newlist := append(list.([]*corev1.Pod)[:i], list.([]*corev1.Pod)[i+1:]...)
-						c.cachesMap.Store(PodSpacePrefix(pod.Namespace), newlist)
-						break
-					}
-				}
-			}
+func (h currentHandler) OnAdd(obj interface{}) {
+	h.handle(func() { h.c.OnAdd(obj) })
+}
 
-		},
-		UpdateFunc: func(oldObj, newObj interface{}) {
-			pod := newObj.(*corev1.Pod)
-			if list, ok := c.cachesMap.Load(PodSpacePrefix(pod.Namespace)); ok {
-				for i, oldPod := range list.([]*corev1.Pod) {
-					if oldPod.Name == pod.Name {
-						list.([]*corev1.Pod)[i] = pod
-					}
-				}
-			}
-		},
-	}
+func (h currentHandler) OnUpdate(oldObj, newObj interface{}) {
+	h.handle(func() { h.c.OnUpdate(oldObj, newObj) })
 }
 
-// AddEndpointsEventHandler return endpoints event handler.
-func (c *controller) AddEndpointsEventHandler() cache.ResourceEventHandlerFuncs {
-	return cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj interface{}) {
-			ep := obj.(*corev1.Endpoints)
-			c.cachesMap.Store(EndpointsSpacePrefix(ep.Namespace), ep)
-		},
-		DeleteFunc: func(obj interface{}) {
-			ep := obj.(*corev1.Endpoints)
-			c.cachesMap.Delete(EndpointsSpacePrefix(ep.Namespace))
-		},
-		UpdateFunc: func(oldObj, newObj interface{}) {
-			ep := newObj.(*corev1.Endpoints)
-			c.cachesMap.Store(EndpointsSpacePrefix(ep.Namespace), ep)
-		},
-	}
+func (h currentHandler) OnDelete(obj interface{}) {
+	h.handle(func() { h.c.OnDelete(obj) })
 }
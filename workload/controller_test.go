@@ -14,54 +14,340 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-package workload
+package workload_test
 
 import (
 	"context"
-	"k8s.io/client-go/kubernetes"
+	"errors"
+	networkingv1alpha3 "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 	"reflect"
-	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
 	"testing"
+	"time"
 	"x6t.io/ggp"
-	"x6t.io/ggp/client"
+	"x6t.io/ggp/ggptest"
+	"x6t.io/ggp/workload"
 )
 
-func NewMockKubeClient() (kubernetes.Interface, context.Context) {
-	clientset, err := client.NewKubeClient(client.NewConfig().KubeAPIConfig)
-	if err != nil {
-		panic(err)
+func newMockPod(name, node string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "edge", Name: name, Labels: map[string]string{"app": "mqtt"}},
+		Spec:       corev1.PodSpec{NodeName: node},
 	}
-	return clientset, signals.SetupSignalHandler()
 }
 
 func TestNewController(t *testing.T) {
-	// NewMockKubeClient new kube client.
-	clientset, ctx := NewMockKubeClient()
-
-	type args struct {
-		clientset kubernetes.Interface
-		stopCh    <-chan struct{}
-	}
 	tests := []struct {
-		name string
-		args args
-		want ggp.ControllerService
+		name    string
+		objects []runtime.Object
+		opts    []workload.Option
+		want    []string
 	}{
 		{
-			name: "new controller",
-			args: args{
-				clientset: clientset,
-				stopCh:    ctx.Done(),
-			},
-			want: nil,
+			name:    "new controller",
+			objects: []runtime.Object{newMockPod("mqtt-0", "node-1"), newMockPod("mqtt-1", "node-2")},
+			want:    []string{"mqtt-0", "mqtt-1"},
+		},
+		{
+			name:    "enabled kinds",
+			objects: []runtime.Object{newMockPod("mqtt-0", "node-1")},
+			opts:    []workload.Option{workload.WithResources(workload.Pod)},
+			want:    []string{"mqtt-0"},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := NewController(tt.args.clientset, tt.args.stopCh); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("NewController() = %v, want %v", got, tt.want)
+			h := ggptest.NewHarness(t, tt.objects, tt.opts...)
+			if !h.Controller.Ready() {
+				t.Errorf("Ready() = false after Start")
+			}
+			h.AssertNames("Pod", "edge", tt.want...)
+
+			h.Create(newMockPod("mqtt-2", "node-1"))
+			h.AssertCached("Pod", "edge", "mqtt-2")
+			pods, err := h.Controller.GetPodByNode("node-1")
+			if err != nil || len(pods) != 2 {
+				t.Errorf("GetPodByNode() = %v, %v, want 2 Pods", pods, err)
+			}
+			h.Delete(newMockPod("mqtt-2", "node-1"))
+			h.AssertNames("Pod", "edge", tt.want...)
+		})
+	}
+}
+
+func TestIstioKinds(t *testing.T) {
+	meta := metav1.ObjectMeta{Namespace: "edge", Name: "mqtt"}
+	h := ggptest.NewHarness(t, []runtime.Object{
+		&networkingv1alpha3.Gateway{ObjectMeta: meta},
+		&networkingv1alpha3.VirtualService{ObjectMeta: meta},
+		&networkingv1alpha3.DestinationRule{ObjectMeta: meta},
+	}, workload.WithResources(workload.Gateway, workload.VirtualService, workload.DestinationRule))
+	for _, kind := range []string{"Gateway", "VirtualService", "DestinationRule"} {
+		h.AssertCached(kind, "edge", "mqtt")
+	}
+	if h.Controller.GatewayLister() == nil || h.Controller.VirtualServiceLister() == nil || h.Controller.DestinationRuleLister() == nil {
+		t.Fatalf("the istio listers are nil")
+	}
+	if _, err := h.Controller.GatewayLister().Gateways("edge").Get("mqtt"); err != nil {
+		t.Errorf("GatewayLister() Get() error = %v", err)
+	}
+	if _, err := h.Controller.VirtualServiceLister().VirtualServices("edge").Get("mqtt"); err != nil {
+		t.Errorf("VirtualServiceLister() Get() error = %v", err)
+	}
+	if _, err := h.Controller.DestinationRuleLister().DestinationRules("edge").Get("mqtt"); err != nil {
+		t.Errorf("DestinationRuleLister() Get() error = %v", err)
+	}
+}
+
+func TestIstioKindsWithoutClient(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	c := workload.NewController(fake.NewSimpleClientset(), stopCh, workload.WithResources(workload.Pod, workload.Gateway))
+	if err := c.Start(context.Background()); !errors.Is(err, workload.ErrNoIstioClient) {
+		t.Errorf("Start() error = %v, want ErrNoIstioClient", err)
+	}
+	if c.Ready() {
+		t.Errorf("Ready() = true without the Gateway informer")
+	}
+	statuses := map[string]error{}
+	for _, status := range c.SyncStatus() {
+		statuses[status.Name] = status.Err
+	}
+	if err, ok := statuses["Gateways"]; !ok || !errors.Is(err, workload.ErrNoIstioClient) {
+		t.Errorf("SyncStatus() Gateways error = %v, want ErrNoIstioClient", err)
+	}
+
+	// the istio kinds are not informed by default without istio client.
+	c = workload.NewController(fake.NewSimpleClientset(), stopCh)
+	if err := c.Start(context.Background()); err != nil {
+		t.Errorf("Start() error = %v", err)
+	}
+}
+
+func TestStartBlocks(t *testing.T) {
+	clientset := fake.NewSimpleClientset(newMockPod("mqtt-0", "node-1"))
+	release := make(chan struct{})
+	clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		<-release
+		return false, nil, nil
+	})
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	c := workload.NewController(clientset, stopCh, workload.WithResources(workload.Pod))
+	started := make(chan error, 1)
+	go func() { started <- c.Start(context.Background()) }()
+
+	select {
+	case err := <-started:
+		t.Fatalf("Start() = %v before the pods are listed", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case err := <-started:
+		if err != nil {
+			t.Fatalf("Start() error = %v", err)
+		}
+	case <-time.After(ggptest.DefaultTimeout):
+		t.Fatalf("Start() did not return once the pods are listed")
+	}
+	if _, err := c.GetPod("edge", "mqtt-0"); err != nil {
+		t.Errorf("GetPod() error = %v once Start returned", err)
+	}
+}
+
+func TestSyncError(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		cancel  bool
+		wantErr error
+	}{
+		{name: "sync timeout", timeout: 200 * time.Millisecond, wantErr: context.DeadlineExceeded},
+		{name: "context done", cancel: true, wantErr: context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset()
+			clientset.PrependReactor("list", "persistentvolumeclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.New("apiserver unavailable")
+			})
+			stopCh := make(chan struct{})
+			defer close(stopCh)
+			c := workload.NewController(clientset, stopCh,
+				workload.WithResources(workload.Pod, workload.PersistentVolumeClaim), workload.WithSyncTimeout(tt.timeout))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				time.AfterFunc(200*time.Millisecond, cancel)
+			}
+
+			err := c.Start(ctx)
+			syncErr := &workload.SyncError{}
+			if !errors.As(err, &syncErr) {
+				t.Fatalf("Start() error = %v, want a *SyncError", err)
+			}
+			if !reflect.DeepEqual(syncErr.Informers, []string{"Claims"}) {
+				t.Errorf("SyncError.Informers = %v, want [Claims]", syncErr.Informers)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Start() error = %v, want %v", err, tt.wantErr)
 			}
 		})
 	}
-	<-ctx.Done()
+}
+
+func TestSyncStatus(t *testing.T) {
+	clientset := fake.NewSimpleClientset(newMockPod("mqtt-0", "node-1"))
+	clientset.PrependReactor("list", "persistentvolumeclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("apiserver unavailable")
+	})
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	c := workload.NewController(clientset, stopCh,
+		workload.WithResources(workload.Pod, workload.PersistentVolumeClaim, workload.StorageClass), workload.WithSyncTimeout(200*time.Millisecond))
+	for _, status := range c.SyncStatus() {
+		if status.Synced {
+			t.Errorf("SyncStatus() %s synced before Start", status.Name)
+		}
+	}
+	_ = c.Start(context.Background())
+
+	got := map[string]ggp.SyncStatus{}
+	for _, status := range c.SyncStatus() {
+		got[status.Name] = status
+	}
+	want := map[string]ggp.SyncStatus{
+		"Pod":          {Name: "Pod", Synced: true, GroupVersion: "v1"},
+		"Claims":       {Name: "Claims", Synced: false, GroupVersion: "v1"},
+		"StorageClass": {Name: "StorageClass", Synced: true, GroupVersion: "storage.k8s.io/v1"},
+	}
+	for name, status := range got {
+		// the fake clientset lists without resource version.
+		status.ResourceVersion = ""
+		got[name] = status
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SyncStatus() = %+v, want %+v", got, want)
+	}
+}
+
+// newMockEvent return the event of the pod mqtt-0.
+func newMockEvent(name string) *corev1.Event {
+	return &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Namespace: "edge", Name: name},
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: "edge", Name: "mqtt-0"},
+		Reason:         "Pulled",
+		LastTimestamp:  metav1.Now(),
+	}
+}
+
+func TestStop(t *testing.T) {
+	clientset := fake.NewSimpleClientset(newMockPod("mqtt-0", "node-1"))
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	c := workload.NewController(clientset, stopCh, workload.WithResources(workload.Pod))
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Stop()
+	if c.Ready() {
+		t.Errorf("Ready() = true once stopped")
+	}
+	if _, err := c.GetPod("edge", "mqtt-0"); !errors.Is(err, workload.ErrNotSynced) {
+		t.Errorf("GetPod() error = %v once stopped, want ErrNotSynced", err)
+	}
+	if _, err := clientset.CoreV1().Pods("edge").Create(context.Background(), newMockPod("mqtt-1", "node-1"), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the informers list again, the pods created while stopped are cached.
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	pods, err := c.GetPodByNameSpace("edge")
+	if err != nil || len(pods) != 2 {
+		t.Errorf("GetPodByNameSpace() = %d pods, %v once started again, want 2", len(pods), err)
+	}
+}
+
+func TestRestart(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	c := workload.NewController(fake.NewSimpleClientset(newMockPod("mqtt-0", "node-1")), stopCh, workload.WithResources(workload.Pod))
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Restart(fake.NewSimpleClientset(newMockPod("broker-0", "node-2"))); err != nil {
+		t.Fatal(err)
+	}
+	// the restarted informers run again, Start waits for them to sync.
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetPod("edge", "mqtt-0"); !errors.Is(err, workload.ErrNotFound) {
+		t.Errorf("GetPod(mqtt-0) error = %v after Restart, want ErrNotFound", err)
+	}
+	if _, err := c.GetPod("edge", "broker-0"); err != nil {
+		t.Errorf("GetPod(broker-0) error = %v after Restart", err)
+	}
+
+	// a stopped controller is restarted stopped.
+	c.Stop()
+	if err := c.Restart(nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.Ready() {
+		t.Errorf("Ready() = true after restarting a stopped controller")
+	}
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetPod("edge", "broker-0"); err != nil {
+		t.Errorf("GetPod(broker-0) error = %v after Restart(nil), want the current client kept", err)
+	}
+}
+
+func TestStopCh(t *testing.T) {
+	clientset := fake.NewSimpleClientset(newMockPod("mqtt-0", "node-1"), newMockEvent("pulled"))
+	stopCh := make(chan struct{})
+	c := workload.NewController(clientset, stopCh, workload.WithResources(workload.Pod, workload.Event))
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	lister := c.PodLister()
+	if groups, err := c.GetEvents("edge", "Pod", "mqtt-0"); err != nil || len(groups) != 1 {
+		t.Fatalf("GetEvents() = %v, %v, want 1 group", groups, err)
+	}
+
+	close(stopCh)
+	// the caches are cleared once the informers stop.
+	deadline := time.Now().Add(ggptest.DefaultTimeout)
+	for {
+		groups, err := c.GetEvents("edge", "Pod", "mqtt-0")
+		if err == nil && len(groups) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("GetEvents() = %v, %v once stopCh is closed, want the caches cleared", groups, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// no informer is built after the final stop.
+	c.Stop()
+	if c.PodLister() != lister {
+		t.Errorf("PodLister() replaced once stopCh is closed")
+	}
+	if err := c.Start(context.Background()); !errors.Is(err, workload.ErrStopped) {
+		t.Errorf("Start() error = %v, want ErrStopped", err)
+	}
+	if err := c.Restart(nil); !errors.Is(err, workload.ErrStopped) {
+		t.Errorf("Restart() error = %v, want ErrStopped", err)
+	}
 }
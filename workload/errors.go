@@ -0,0 +1,85 @@
+/*
+Copyright 2021 The Gridsum Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workload
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrStopped is returned once the stopCh given to NewController is closed.
+	ErrStopped = errors.New("controller stopped")
+	// ErrNotFound is returned when the object is not in the cache.
+	ErrNotFound = errors.New("not found")
+	// ErrNotSynced is returned when the informer of the kind has not synced yet.
+	ErrNotSynced = errors.New("cache not synced")
+	// ErrKindDisabled is returned when the kind has no informer, see WithResources, WithIstioClient and RegisterDynamic.
+	ErrKindDisabled = errors.New("kind not enabled")
+	// ErrMetadataOnly is returned by the listers of the kinds informed as metadata only, see WithMetadataOnly.
+	ErrMetadataOnly = errors.New("kind informed as metadata only")
+	// ErrNamespaceNotWatched is returned when the namespace is outside WithNamespace or WithNamespaceSelector.
+	ErrNamespaceNotWatched = errors.New("namespace not watched")
+	// ErrNoDynamicClient is returned by RegisterDynamic without a dynamic client, see WithDynamicClient.
+	ErrNoDynamicClient = errors.New("no dynamic client")
+	// ErrNoIstioClient is returned by Start when an istio kind is enabled without an istio client, see WithIstioClient.
+	ErrNoIstioClient = errors.New("no istio client")
+)
+
+// QueryError is returned by the queries, use errors.Is to check the reason, such as ErrNotFound.
+type QueryError struct {
+	// Kind is the queried kind, such as Pod.
+	Kind string
+	// Namespace is the queried namespace, empty for all namespaces or cluster scoped kinds.
+	Namespace string
+	// Name is the queried name, empty for lists.
+	Name string
+	// Err is one of ErrNotFound, ErrNotSynced, ErrKindDisabled and ErrNamespaceNotWatched.
+	Err error
+}
+
+func (e *QueryError) Error() string {
+	target := e.Kind
+	switch {
+	case e.Name != "":
+		target += " " + namespaceKey(e.Namespace, e.Name)
+	case e.Namespace != "":
+		target += " in namespace " + e.Namespace
+	}
+	return target + ": " + e.Err.Error()
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// SyncError is returned by Start when some informers have not synced in time.
+type SyncError struct {
+	// Informers is the field name of the unsynced informers, such as Claims or Gateways.
+	Informers []string
+	// Err is the reason Start gave up, such as context.DeadlineExceeded.
+	Err error
+}
+
+func (e *SyncError) Error() string {
+	return fmt.Sprintf("informers %s have not synced: %v", strings.Join(e.Informers, ", "), e.Err)
+}
+
+func (e *SyncError) Unwrap() error {
+	return e.Err
+}
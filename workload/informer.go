@@ -16,7 +16,30 @@ limitations under the License.
 
 package workload
 
-import "k8s.io/client-go/tools/cache"
+import (
+	"context"
+	"fmt"
+	networkingv1alpha3 "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	istio "istio.io/client-go/pkg/clientset/versioned"
+	istioexternalversions "istio.io/client-go/pkg/informers/externalversions"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	corev1 "k8s.io/api/core/v1"
+	extensions "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/metadata/metadatainformer"
+	"k8s.io/client-go/tools/cache"
+	"sort"
+)
 
 type Informer struct {
 	Namespace               cache.SharedIndexInformer
@@ -34,53 +57,446 @@ type Informer struct {
 	Claims                  cache.SharedIndexInformer
 	Events                  cache.SharedIndexInformer
 	HorizontalPodAutoscaler cache.SharedIndexInformer
-	// TODO
+	// istio v1alpha3
 	Gateways        cache.SharedIndexInformer
 	VirtualService  cache.SharedIndexInformer
 	DestinationRule cache.SharedIndexInformer
+	// versions is the informed group version of the kinds, see kindInfo.resources.
+	versions map[PrefixType]schema.GroupVersion
+	// metadataOnly is the kinds informed as metadata only, see WithMetadataOnly.
+	metadataOnly map[PrefixType]bool
+	// dynamic is the informers of the resources registered by RegisterDynamic.
+	dynamic *dynamicInformers
+	// errs is why the informers of the enabled kinds without informer could not be built.
+	errs map[PrefixType]error
+	// fanouts is the handler shared by the subscriptions of each informer, see Subscribe.
+	fanouts map[cache.SharedIndexInformer]*fanout
 }
 
+// MetadataOnly return true if the informer of the kind stores *metav1.PartialObjectMetadata.
+func (i *Informer) MetadataOnly(kind PrefixType) bool {
+	return i.metadataOnly[kind]
+}
+
+// setMetadataOnly assign the metadata only informer of the kind informed in the group version.
+func (i *Informer) setMetadataOnly(kind PrefixType, gv schema.GroupVersion, informer cache.SharedIndexInformer) {
+	if i.metadataOnly == nil {
+		i.metadataOnly = map[PrefixType]bool{}
+	}
+	i.metadataOnly[kind] = true
+	i.setVersioned(kind, gv, informer)
+}
+
+// GroupVersion return the group version the kind is informed in, such as networking.k8s.io/v1 for Ingress.
+func (i *Informer) GroupVersion(kind PrefixType) schema.GroupVersion {
+	if gv, ok := i.versions[kind]; ok {
+		return gv
+	}
+	return preferredResource(kind).gvr.GroupVersion()
+}
+
+// setVersioned assign the informer of the kind informed in the group version.
+func (i *Informer) setVersioned(kind PrefixType, gv schema.GroupVersion, informer cache.SharedIndexInformer) {
+	if i.versions == nil {
+		i.versions = map[PrefixType]schema.GroupVersion{}
+	}
+	i.versions[kind] = gv
+	i.Set(kind, informer)
+}
+
+// Get return the informer of the kind, nil if the kind has no informer.
+func (i *Informer) Get(kind PrefixType) cache.SharedIndexInformer {
+	switch kind {
+	case NameSpace:
+		return i.Namespace
+	case Ingress:
+		return i.Ingress
+	case Pod:
+		return i.Pod
+	case Service:
+		return i.Service
+	case Secret:
+		return i.Secret
+	case StatefulSet:
+		return i.StatefulSet
+	case Event:
+		return i.Events
+	case Deployment:
+		return i.Deployment
+	case ConfigMap:
+		return i.ConfigMap
+	case ReplicaSet:
+		return i.ReplicaSet
+	case StorageClass:
+		return i.StorageClass
+	case PersistentVolumeClaim:
+		return i.Claims
+	case Endpoints:
+		return i.Endpoints
+	case HorizontalPodAutoscaler:
+		return i.HorizontalPodAutoscaler
+	case Node:
+		return i.Nodes
+	case Gateway:
+		return i.Gateways
+	case VirtualService:
+		return i.VirtualService
+	case DestinationRule:
+		return i.DestinationRule
+	default:
+		return nil
+	}
+}
+
+// Set assign the informer of the kind.
+func (i *Informer) Set(kind PrefixType, informer cache.SharedIndexInformer) {
+	switch kind {
+	case NameSpace:
+		i.Namespace = informer
+	case Ingress:
+		i.Ingress = informer
+	case Pod:
+		i.Pod = informer
+	case Service:
+		i.Service = informer
+	case Secret:
+		i.Secret = informer
+	case StatefulSet:
+		i.StatefulSet = informer
+	case Event:
+		i.Events = informer
+	case Deployment:
+		i.Deployment = informer
+	case ConfigMap:
+		i.ConfigMap = informer
+	case ReplicaSet:
+		i.ReplicaSet = informer
+	case StorageClass:
+		i.StorageClass = informer
+	case PersistentVolumeClaim:
+		i.Claims = informer
+	case Endpoints:
+		i.Endpoints = informer
+	case HorizontalPodAutoscaler:
+		i.HorizontalPodAutoscaler = informer
+	case Node:
+		i.Nodes = informer
+	case Gateway:
+		i.Gateways = informer
+	case VirtualService:
+		i.VirtualService = informer
+	case DestinationRule:
+		i.DestinationRule = informer
+	}
+}
+
+// Each call fn with the field name of every enabled informer, disabled informers are nil and skipped.
+func (i *Informer) Each(fn func(name string, informer cache.SharedIndexInformer)) {
+	for _, kind := range sortedKinds() {
+		if informer := i.Get(kind); informer != nil {
+			fn(kinds[kind].name, informer)
+		}
+	}
+	i.eachDynamic(func(gvr schema.GroupVersionResource, informer cache.SharedIndexInformer) {
+		fn(dynamicName(gvr), informer)
+	})
+}
+
+// setError record why the informer of the enabled kind could not be built.
+func (i *Informer) setError(kind PrefixType, err error) {
+	if i.errs == nil {
+		i.errs = map[PrefixType]error{}
+	}
+	i.errs[kind] = fmt.Errorf("inform %s: %w", kind, err)
+}
+
+// eachError call fn with the field name of every enabled informer which could not be built and the reason.
+func (i *Informer) eachError(fn func(name string, err error)) {
+	for _, kind := range sortedKinds() {
+		if err, ok := i.errs[kind]; ok {
+			fn(kinds[kind].name, err)
+		}
+	}
+}
+
+// err return the reasons the informers of the enabled kinds could not be built, nil if they all were.
+func (i *Informer) err() error {
+	var errs []error
+	i.eachError(func(_ string, err error) {
+		errs = append(errs, err)
+	})
+	return utilerrors.NewAggregate(errs)
+}
+
+// Ready return true once every enabled informer has synced, never if one could not be built.
 func (i *Informer) Ready() bool {
-	if i.Namespace.HasSynced() &&
-		i.Ingress.HasSynced() &&
-		i.Service.HasSynced() &&
-		i.Secret.HasSynced() &&
-		i.StatefulSet.HasSynced() &&
-		i.Deployment.HasSynced() &&
-		i.Pod.HasSynced() &&
-		i.ConfigMap.HasSynced() &&
-		i.Nodes.HasSynced() &&
-		i.Events.HasSynced() &&
-		i.HorizontalPodAutoscaler.HasSynced() &&
-		i.StorageClass.HasSynced() &&
-		i.Claims.HasSynced() &&
-		// TODO
-		i.Gateways.HasSynced() &&
-		i.VirtualService.HasSynced() &&
-		i.DestinationRule.HasSynced() {
-		return true
-	}
-	return false
+	ready := len(i.errs) == 0
+	i.Each(func(_ string, informer cache.SharedIndexInformer) {
+		ready = ready && informer.HasSynced()
+	})
+	return ready
 }
 
+// Start run every enabled informer until stop is closed.
 func (i *Informer) Start(stop <-chan struct{}) {
-	go i.Namespace.Run(stop)
-	go i.Ingress.Run(stop)
-	go i.Service.Run(stop)
-	go i.Secret.Run(stop)
-	go i.StatefulSet.Run(stop)
-	go i.Deployment.Run(stop)
-	go i.Pod.Run(stop)
-	go i.ConfigMap.Run(stop)
-	go i.ReplicaSet.Run(stop)
-	go i.Endpoints.Run(stop)
-	go i.Nodes.Run(stop)
-	go i.StorageClass.Run(stop)
-	go i.Events.Run(stop)
-	go i.HorizontalPodAutoscaler.Run(stop)
-	go i.Claims.Run(stop)
-	// TODO
-	go i.Gateways.Run(stop)
-	go i.VirtualService.Run(stop)
-	go i.DestinationRule.Run(stop)
+	i.Each(func(_ string, informer cache.SharedIndexInformer) {
+		go informer.Run(stop)
+	})
+}
+
+// kindInfo is how the controller informs one kind.
+type kindInfo struct {
+	// name is the name of the informer of the kind in SyncStatus, its field in Informer such as Claims.
+	name string
+	// resources is the resources the kind is served as in order of preference, the first one served by
+	// the apiserver is informed, see servedVersions. The objects are stored as the first one.
+	resources []kindResource
+	// clusterScoped is true for the kinds without namespace.
+	clusterScoped bool
+	// istio is true for the kinds of the istio client, see WithIstioClient.
+	istio bool
+}
+
+// kindResource is one resource a kind is served as.
+type kindResource struct {
+	gvr schema.GroupVersionResource
+	// listWatch return the list watch of the resource through its typed client.
+	listWatch listWatchFunc
+	// conversion convert the objects to the preferred resource of the kind, nil for the preferred one.
+	conversion *conversion
+}
+
+// listWatchFunc return the list watch of a resource under the namespace, all namespaces if empty,
+// through the typed client of the kube or the istio clientset.
+type listWatchFunc func(c kubernetes.Interface, ic istio.Interface, ns string) *cache.ListWatch
+
+// kinds is how every kind is informed.
+var kinds = map[PrefixType]kindInfo{
+	NameSpace: {name: "Namespace", clusterScoped: true, resources: []kindResource{
+		{gvr: corev1.SchemeGroupVersion.WithResource("namespaces"), listWatch: func(c kubernetes.Interface, _ istio.Interface, _ string) *cache.ListWatch {
+			client := c.CoreV1().Namespaces()
+			return newListWatch(func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+				return client.List(ctx, options)
+			}, client.Watch)
+		}},
+	}},
+	Ingress: {name: "Ingress", resources: []kindResource{
+		{gvr: networkingv1.SchemeGroupVersion.WithResource("ingresses"), listWatch: func(c kubernetes.Interface, _ istio.Interface, ns string) *cache.ListWatch {
+			client := c.NetworkingV1().Ingresses(ns)
+			return newListWatch(func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+				return client.List(ctx, options)
+			}, client.Watch)
+		}},
+		{gvr: extensions.SchemeGroupVersion.WithResource("ingresses"), listWatch: func(c kubernetes.Interface, _ istio.Interface, ns string) *cache.ListWatch {
+			client := c.ExtensionsV1beta1().Ingresses(ns)
+			return newListWatch(func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+				return client.List(ctx, options)
+			}, client.Watch)
+		}, conversion: ingressConversion},
+	}},
+	Pod: {name: "Pod", resources: []kindResource{
+		{gvr: corev1.SchemeGroupVersion.WithResource("pods"), listWatch: func(c kubernetes.Interface, _ istio.Interface, ns string) *cache.ListWatch {
+			client := c.CoreV1().Pods(ns)
+			return newListWatch(func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+				return client.List(ctx, options)
+			}, client.Watch)
+		}},
+	}},
+	Service: {name: "Service", resources: []kindResource{
+		{gvr: corev1.SchemeGroupVersion.WithResource("services"), listWatch: func(c kubernetes.Interface, _ istio.Interface, ns string) *cache.ListWatch {
+			client := c.CoreV1().Services(ns)
+			return newListWatch(func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+				return client.List(ctx, options)
+			}, client.Watch)
+		}},
+	}},
+	Secret: {name: "Secret", resources: []kindResource{
+		{gvr: corev1.SchemeGroupVersion.WithResource("secrets"), listWatch: func(c kubernetes.Interface, _ istio.Interface, ns string) *cache.ListWatch {
+			client := c.CoreV1().Secrets(ns)
+			return newListWatch(func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+				return client.List(ctx, options)
+			}, client.Watch)
+		}},
+	}},
+	StatefulSet: {name: "StatefulSet", resources: []kindResource{
+		{gvr: appsv1.SchemeGroupVersion.WithResource("statefulsets"), listWatch: func(c kubernetes.Interface, _ istio.Interface, ns string) *cache.ListWatch {
+			client := c.AppsV1().StatefulSets(ns)
+			return newListWatch(func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+				return client.List(ctx, options)
+			}, client.Watch)
+		}},
+	}},
+	Event: {name: "Events", resources: []kindResource{
+		{gvr: corev1.SchemeGroupVersion.WithResource("events"), listWatch: func(c kubernetes.Interface, _ istio.Interface, ns string) *cache.ListWatch {
+			client := c.CoreV1().Events(ns)
+			return newListWatch(func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+				return client.List(ctx, options)
+			}, client.Watch)
+		}},
+	}},
+	Deployment: {name: "Deployment", resources: []kindResource{
+		{gvr: appsv1.SchemeGroupVersion.WithResource("deployments"), listWatch: func(c kubernetes.Interface, _ istio.Interface, ns string) *cache.ListWatch {
+			client := c.AppsV1().Deployments(ns)
+			return newListWatch(func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+				return client.List(ctx, options)
+			}, client.Watch)
+		}},
+	}},
+	ConfigMap: {name: "ConfigMap", resources: []kindResource{
+		{gvr: corev1.SchemeGroupVersion.WithResource("configmaps"), listWatch: func(c kubernetes.Interface, _ istio.Interface, ns string) *cache.ListWatch {
+			client := c.CoreV1().ConfigMaps(ns)
+			return newListWatch(func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+				return client.List(ctx, options)
+			}, client.Watch)
+		}},
+	}},
+	ReplicaSet: {name: "ReplicaSet", resources: []kindResource{
+		{gvr: appsv1.SchemeGroupVersion.WithResource("replicasets"), listWatch: func(c kubernetes.Interface, _ istio.Interface, ns string) *cache.ListWatch {
+			client := c.AppsV1().ReplicaSets(ns)
+			return newListWatch(func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+				return client.List(ctx, options)
+			}, client.Watch)
+		}},
+	}},
+	StorageClass: {name: "StorageClass", clusterScoped: true, resources: []kindResource{
+		{gvr: storagev1.SchemeGroupVersion.WithResource("storageclasses"), listWatch: func(c kubernetes.Interface, _ istio.Interface, _ string) *cache.ListWatch {
+			client := c.StorageV1().StorageClasses()
+			return newListWatch(func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+				return client.List(ctx, options)
+			}, client.Watch)
+		}},
+	}},
+	PersistentVolumeClaim: {name: "Claims", resources: []kindResource{
+		{gvr: corev1.SchemeGroupVersion.WithResource("persistentvolumeclaims"), listWatch: func(c kubernetes.Interface, _ istio.Interface, ns string) *cache.ListWatch {
+			client := c.CoreV1().PersistentVolumeClaims(ns)
+			return newListWatch(func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+				return client.List(ctx, options)
+			}, client.Watch)
+		}},
+	}},
+	Endpoints: {name: "Endpoints", resources: []kindResource{
+		{gvr: corev1.SchemeGroupVersion.WithResource("endpoints"), listWatch: func(c kubernetes.Interface, _ istio.Interface, ns string) *cache.ListWatch {
+			client := c.CoreV1().Endpoints(ns)
+			return newListWatch(func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+				return client.List(ctx, options)
+			}, client.Watch)
+		}},
+	}},
+	HorizontalPodAutoscaler: {name: "HorizontalPodAutoscaler", resources: []kindResource{
+		{gvr: autoscalingv2.SchemeGroupVersion.WithResource("horizontalpodautoscalers"), listWatch: func(c kubernetes.Interface, _ istio.Interface, ns string) *cache.ListWatch {
+			client := c.AutoscalingV2().HorizontalPodAutoscalers(ns)
+			return newListWatch(func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+				return client.List(ctx, options)
+			}, client.Watch)
+		}},
+		{gvr: autoscalingv2beta2.SchemeGroupVersion.WithResource("horizontalpodautoscalers"), listWatch: func(c kubernetes.Interface, _ istio.Interface, ns string) *cache.ListWatch {
+			client := c.AutoscalingV2beta2().HorizontalPodAutoscalers(ns)
+			return newListWatch(func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+				return client.List(ctx, options)
+			}, client.Watch)
+		}, conversion: horizontalPodAutoscalerConversion},
+	}},
+	Node: {name: "Nodes", clusterScoped: true, resources: []kindResource{
+		{gvr: corev1.SchemeGroupVersion.WithResource("nodes"), listWatch: func(c kubernetes.Interface, _ istio.Interface, _ string) *cache.ListWatch {
+			client := c.CoreV1().Nodes()
+			return newListWatch(func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+				return client.List(ctx, options)
+			}, client.Watch)
+		}},
+	}},
+	Gateway: {name: "Gateways", istio: true, resources: []kindResource{
+		{gvr: networkingv1alpha3.SchemeGroupVersion.WithResource("gateways"), listWatch: func(_ kubernetes.Interface, ic istio.Interface, ns string) *cache.ListWatch {
+			client := ic.NetworkingV1alpha3().Gateways(ns)
+			return newListWatch(func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+				return client.List(ctx, options)
+			}, client.Watch)
+		}},
+	}},
+	VirtualService: {name: "VirtualService", istio: true, resources: []kindResource{
+		{gvr: networkingv1alpha3.SchemeGroupVersion.WithResource("virtualservices"), listWatch: func(_ kubernetes.Interface, ic istio.Interface, ns string) *cache.ListWatch {
+			client := ic.NetworkingV1alpha3().VirtualServices(ns)
+			return newListWatch(func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+				return client.List(ctx, options)
+			}, client.Watch)
+		}},
+	}},
+	DestinationRule: {name: "DestinationRule", istio: true, resources: []kindResource{
+		{gvr: networkingv1alpha3.SchemeGroupVersion.WithResource("destinationrules"), listWatch: func(_ kubernetes.Interface, ic istio.Interface, ns string) *cache.ListWatch {
+			client := ic.NetworkingV1alpha3().DestinationRules(ns)
+			return newListWatch(func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+				return client.List(ctx, options)
+			}, client.Watch)
+		}},
+	}},
+}
+
+// sortedKinds return every kind of kinds, sorted.
+func sortedKinds() []PrefixType {
+	ret := make([]PrefixType, 0, len(kinds))
+	for kind := range kinds {
+		ret = append(ret, kind)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	return ret
+}
+
+// preferredResource return the preferred resource of the kind, the one its objects are stored as.
+func preferredResource(kind PrefixType) kindResource {
+	if info, ok := kinds[kind]; ok {
+		return info.resources[0]
+	}
+	return kindResource{}
+}
+
+// newKindInformer create the informer of the kind served as the resource r. The informers of the shared
+// informer factories of the kube and istio clients are used, unless the objects are informed as metadata
+// only, see WithMetadataOnly, or transformed or converted before they are stored, see newTransformingInformer.
+func newKindInformer(client kubernetes.Interface, o *options, kind PrefixType, r kindResource, indexers cache.Indexers) (cache.SharedIndexInformer, error) {
+	info := kinds[kind]
+	namespace := o.namespace
+	if info.clusterScoped {
+		namespace = ""
+	}
+	resync, tweak, transform := o.resyncFor(kind), o.tweakFor(kind), o.transformFor(kind)
+	if transform != nil || r.conversion != nil {
+		var metadataClient metadata.Interface
+		if o.metadataOnly(kind) {
+			metadataClient = o.metadataClient
+		}
+		return newTransformingInformer(client, o.istioClient, metadataClient, kind, r, namespace, resync, indexers, tweak, transform)
+	}
+	if o.metadataOnly(kind) {
+		return metadatainformer.NewFilteredMetadataInformer(o.metadataClient, r.gvr, namespace, resync, indexers, tweak).Informer(), nil
+	}
+
+	var generic interface {
+		Informer() cache.SharedIndexInformer
+	}
+	var err error
+	if info.istio {
+		// each kind has its own factory, as the resync and the list options tweak are set per kind.
+		factory := istioexternalversions.NewSharedInformerFactoryWithOptions(o.istioClient, resync,
+			istioexternalversions.WithNamespace(namespace), istioexternalversions.WithTweakListOptions(tweak))
+		generic, err = factory.ForResource(r.gvr)
+	} else {
+		factory := informers.NewSharedInformerFactoryWithOptions(client, resync,
+			informers.WithNamespace(namespace), informers.WithTweakListOptions(tweak))
+		generic, err = factory.ForResource(r.gvr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	informer := generic.Informer()
+	// the informers of the factories are already indexed by namespace.
+	added := cache.Indexers{}
+	existing := informer.GetIndexer().GetIndexers()
+	for name, indexFunc := range indexers {
+		if _, ok := existing[name]; !ok {
+			added[name] = indexFunc
+		}
+	}
+	if err := informer.AddIndexers(added); err != nil {
+		return nil, err
+	}
+	return informer, nil
 }
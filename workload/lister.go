@@ -19,27 +19,140 @@ package workload
 import (
 	istio "istio.io/client-go/pkg/listers/networking/v1alpha3"
 	appsv1 "k8s.io/client-go/listers/apps/v1"
-	autoscalingv2 "k8s.io/client-go/listers/autoscaling/v2beta2"
+	autoscalingv2 "k8s.io/client-go/listers/autoscaling/v2"
 	corev1 "k8s.io/client-go/listers/core/v1"
-	"k8s.io/client-go/listers/extensions/v1beta1"
+	networkingv1 "k8s.io/client-go/listers/networking/v1"
 	storagev1 "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/client-go/tools/cache"
 )
 
 type Lister struct {
-	Ingress                 v1beta1.IngressLister
+	Namespace               corev1.NamespaceLister
+	Ingress                 networkingv1.IngressLister
 	Service                 corev1.ServiceLister
 	Secret                  corev1.SecretLister
 	StatefulSet             appsv1.StatefulSetLister
 	Deployment              appsv1.DeploymentLister
+	ReplicaSet              appsv1.ReplicaSetLister
 	Pod                     corev1.PodLister
 	ConfigMap               corev1.ConfigMapLister
 	Endpoints               corev1.EndpointsLister
 	Nodes                   corev1.NodeLister
 	StorageClass            storagev1.StorageClassLister
 	Claims                  corev1.PersistentVolumeClaimLister
+	Event                   corev1.EventLister
 	HorizontalPodAutoscaler autoscalingv2.HorizontalPodAutoscalerLister
-	// TODO v1alpha3
+	// v1alpha3
 	Gateways        istio.GatewayLister
 	VirtualService  istio.VirtualServiceLister
 	DestinationRule istio.DestinationRuleLister
 }
+
+// newLister return the listers of every kind, the listers of the kinds without typed store are backed by
+// an errorIndexer: the kinds not informed, see WithResources, and the kinds informed as metadata only,
+// as their stored objects would not cast. The Ingress and HorizontalPodAutoscaler are stored as
+// networking.k8s.io/v1 and autoscaling/v2 whichever version is informed, see controller-convert.go.
+func newLister(i *Informer) *Lister {
+	indexer := func(kind PrefixType) cache.Indexer {
+		informer := i.Get(kind)
+		switch {
+		case informer == nil:
+			return newErrorIndexer(&QueryError{Kind: kind.String(), Err: ErrKindDisabled})
+		case i.MetadataOnly(kind):
+			return newErrorIndexer(&QueryError{Kind: kind.String(), Err: ErrMetadataOnly})
+		}
+		return informer.GetIndexer()
+	}
+	return &Lister{
+		Namespace:               corev1.NewNamespaceLister(indexer(NameSpace)),
+		Ingress:                 networkingv1.NewIngressLister(indexer(Ingress)),
+		Service:                 corev1.NewServiceLister(indexer(Service)),
+		Secret:                  corev1.NewSecretLister(indexer(Secret)),
+		StatefulSet:             appsv1.NewStatefulSetLister(indexer(StatefulSet)),
+		Deployment:              appsv1.NewDeploymentLister(indexer(Deployment)),
+		ReplicaSet:              appsv1.NewReplicaSetLister(indexer(ReplicaSet)),
+		Pod:                     corev1.NewPodLister(indexer(Pod)),
+		ConfigMap:               corev1.NewConfigMapLister(indexer(ConfigMap)),
+		Endpoints:               corev1.NewEndpointsLister(indexer(Endpoints)),
+		Nodes:                   corev1.NewNodeLister(indexer(Node)),
+		StorageClass:            storagev1.NewStorageClassLister(indexer(StorageClass)),
+		Claims:                  corev1.NewPersistentVolumeClaimLister(indexer(PersistentVolumeClaim)),
+		Event:                   corev1.NewEventLister(indexer(Event)),
+		HorizontalPodAutoscaler: autoscalingv2.NewHorizontalPodAutoscalerLister(indexer(HorizontalPodAutoscaler)),
+		Gateways:                istio.NewGatewayLister(indexer(Gateway)),
+		VirtualService:          istio.NewVirtualServiceLister(indexer(VirtualService)),
+		DestinationRule:         istio.NewDestinationRuleLister(indexer(DestinationRule)),
+	}
+}
+
+// errorIndexer is the empty store of the listers of the kinds without typed store, see newLister.
+// Its gets return err, the lists of the client-go listers can not return an error and return nothing.
+type errorIndexer struct {
+	err error
+}
+
+// newErrorIndexer return the empty store whose gets and writes return err.
+func newErrorIndexer(err error) cache.Indexer {
+	return &errorIndexer{err: err}
+}
+
+func (e *errorIndexer) Add(interface{}) error {
+	return e.err
+}
+
+func (e *errorIndexer) Update(interface{}) error {
+	return e.err
+}
+
+func (e *errorIndexer) Delete(interface{}) error {
+	return e.err
+}
+
+func (e *errorIndexer) List() []interface{} {
+	return nil
+}
+
+func (e *errorIndexer) ListKeys() []string {
+	return nil
+}
+
+func (e *errorIndexer) Get(interface{}) (interface{}, bool, error) {
+	return nil, false, e.err
+}
+
+func (e *errorIndexer) GetByKey(string) (interface{}, bool, error) {
+	return nil, false, e.err
+}
+
+func (e *errorIndexer) Replace([]interface{}, string) error {
+	return e.err
+}
+
+func (e *errorIndexer) Resync() error {
+	return e.err
+}
+
+// Index return nothing, the namespaced lists of the client-go listers log the index errors and list the store.
+func (e *errorIndexer) Index(string, interface{}) ([]interface{}, error) {
+	return nil, nil
+}
+
+func (e *errorIndexer) IndexKeys(string, string) ([]string, error) {
+	return nil, nil
+}
+
+func (e *errorIndexer) ListIndexFuncValues(string) []string {
+	return nil
+}
+
+func (e *errorIndexer) ByIndex(string, string) ([]interface{}, error) {
+	return nil, nil
+}
+
+func (e *errorIndexer) GetIndexers() cache.Indexers {
+	return cache.Indexers{}
+}
+
+func (e *errorIndexer) AddIndexers(cache.Indexers) error {
+	return e.err
+}